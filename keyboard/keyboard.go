@@ -0,0 +1,193 @@
+package keyboard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultDelay - time between keystrokes when none is given
+const DefaultDelay = 100 * time.Millisecond
+
+// Keystroke - keys pressed together, as QEMU qcodes with the modifiers
+// first, or a pause when Keys is empty
+type Keystroke struct {
+	Keys []string
+	Wait time.Duration
+}
+
+func (k Keystroke) IsWait() bool {
+	return len(k.Keys) == 0
+}
+
+// String - the keystroke in the QEMU sendkey syntax, like "shift-a"
+func (k Keystroke) String() string {
+	if k.IsWait() {
+		return "<wait" + k.Wait.String() + ">"
+	}
+	return strings.Join(k.Keys, "-")
+}
+
+// names usable in <...> escapes besides the layout characters and qcodes
+var specialKeys = map[string]string{
+	"enter":      "ret",
+	"return":     "ret",
+	"tab":        "tab",
+	"esc":        "esc",
+	"bs":         "backspace",
+	"del":        "delete",
+	"spacebar":   "spc",
+	"insert":     "insert",
+	"home":       "home",
+	"end":        "end",
+	"pageup":     "pgup",
+	"pagedown":   "pgdn",
+	"up":         "up",
+	"down":       "down",
+	"left":       "left",
+	"right":      "right",
+	"menu":       "menu",
+	"ctrl":       "ctrl",
+	"leftctrl":   "ctrl",
+	"rightctrl":  "ctrl_r",
+	"alt":        "alt",
+	"leftalt":    "alt",
+	"rightalt":   "alt_r",
+	"shift":      "shift",
+	"leftshift":  "shift",
+	"rightshift": "shift_r",
+	"super":      "meta_l",
+	"leftsuper":  "meta_l",
+	"rightsuper": "meta_r",
+	"win":        "meta_l",
+}
+
+// qcodes - the QEMU key codes, which can be used by name in <...> escapes
+var qcodes = map[string]bool{}
+
+func init() {
+	for _, qcode := range strings.Fields(`
+		shift shift_r alt alt_r ctrl ctrl_r meta_l meta_r menu
+		esc 1 2 3 4 5 6 7 8 9 0 minus equal backspace tab
+		q w e r t y u i o p bracket_left bracket_right ret
+		a s d f g h j k l semicolon apostrophe grave_accent backslash
+		z x c v b n m comma dot slash asterisk spc caps_lock
+		f1 f2 f3 f4 f5 f6 f7 f8 f9 f10 f11 f12
+		num_lock scroll_lock kp_divide kp_multiply kp_subtract kp_add kp_enter
+		kp_decimal sysrq kp_0 kp_1 kp_2 kp_3 kp_4 kp_5 kp_6 kp_7 kp_8 kp_9
+		less print home pgup pgdn end left up down right insert delete
+		stop again props undo front copy open paste find cut lf help
+		compose pause power sleep wake audionext audioprev audiostop
+		audioplay audiomute volumeup volumedown mediaselect mail calculator
+		computer ac_home ac_back ac_forward ac_refresh ac_bookmarks`) {
+		qcodes[qcode] = true
+	}
+}
+
+func IsQcode(name string) bool {
+	return qcodes[name]
+}
+
+// Parse - translate text to the keystrokes typing it with layout
+// Special keys and chords go between angle brackets, as in <enter>, <f2>,
+// <ctrl-alt-del> or <leftshift-tab>, any QEMU qcode can be used by name.
+// <wait> pauses a second, <waitN> N seconds and <waitD> a Go duration as
+// <wait500ms>. <lt> and <gt> type the brackets, which are also typed as is
+// when they don't enclose a valid escape.
+func Parse(text string, layout Layout) (keystrokes []Keystroke, err error) {
+	for i := 0; i < len(text); {
+		if text[i] == '<' {
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				if keystroke, isSpecial := parseSpecial(text[i+1:i+end], layout); isSpecial {
+					keystrokes = append(keystrokes, keystroke)
+					i += end + 1
+					continue
+				}
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(text[i:])
+		keys, isSet := layout.Keys(r)
+		if !isSet {
+			return nil, fmt.Errorf("Can't type %q with the %s layout", r, layout.Name())
+		}
+		keystrokes = append(keystrokes, Keystroke{Keys: keys})
+		i += size
+	}
+
+	return
+}
+
+func parseWait(name string) (wait time.Duration, isWait bool) {
+	if !strings.HasPrefix(name, "wait") {
+		return
+	}
+
+	arg := strings.TrimPrefix(name, "wait")
+	if arg == "" {
+		return time.Second, true
+	}
+	if seconds, err := strconv.Atoi(arg); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if duration, err := time.ParseDuration(arg); err == nil && duration >= 0 {
+		return duration, true
+	}
+
+	return
+}
+
+func parseSpecial(name string, layout Layout) (keystroke Keystroke, isSpecial bool) {
+	lower := strings.ToLower(name)
+
+	if wait, isWait := parseWait(lower); isWait {
+		return Keystroke{Wait: wait}, true
+	}
+
+	switch lower {
+	case "lt":
+		name = "<"
+	case "gt":
+		name = ">"
+	}
+
+	// characters are only escaped in chords (<a> is typed as is), where they
+	// keep their case, so <ctrl-C> is ctrl-shift-c
+	parts := strings.Split(name, "-")
+	if len(parts) == 1 && utf8.RuneCountInString(name) == 1 && lower != "lt" && lower != "gt" {
+		return Keystroke{}, false
+	}
+	for _, part := range parts {
+		var keys []string
+		if qcode, isSet := specialKeys[strings.ToLower(part)]; isSet {
+			keys = []string{qcode}
+		} else if IsQcode(strings.ToLower(part)) && utf8.RuneCountInString(part) > 1 {
+			keys = []string{strings.ToLower(part)}
+		} else if r, size := utf8.DecodeRuneInString(part); size > 0 && size == len(part) {
+			if keys, isSet = layout.Keys(r); !isSet {
+				return Keystroke{}, false
+			}
+		} else {
+			return Keystroke{}, false
+		}
+
+		for _, key := range keys {
+			if !inList(keystroke.Keys, key) {
+				keystroke.Keys = append(keystroke.Keys, key)
+			}
+		}
+	}
+
+	return keystroke, true
+}
+
+func inList(list []string, item string) bool {
+	for _, elem := range list {
+		if elem == item {
+			return true
+		}
+	}
+	return false
+}
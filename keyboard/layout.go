@@ -0,0 +1,69 @@
+package keyboard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Layout - how to type each character, as the QEMU qcodes to press together
+type Layout interface {
+	Name() string
+	Keys(r rune) (keys []string, isSet bool)
+}
+
+// MapLayout - a layout from a character map
+type MapLayout struct {
+	name string
+	keys map[rune][]string
+}
+
+func NewMapLayout(name string, keys map[rune][]string) *MapLayout {
+	return &MapLayout{name: name, keys: keys}
+}
+
+func (layout *MapLayout) Name() string {
+	return layout.name
+}
+
+func (layout *MapLayout) Keys(r rune) (keys []string, isSet bool) {
+	keys, isSet = layout.keys[r]
+	return
+}
+
+var (
+	layoutsMutex sync.RWMutex
+	layouts      = map[string]Layout{}
+)
+
+// RegisterLayout - make a layout available by name to GetLayout, replacing
+// any layout registered with the same name
+func RegisterLayout(layout Layout) {
+	layoutsMutex.Lock()
+	defer layoutsMutex.Unlock()
+	layouts[strings.ToLower(layout.Name())] = layout
+}
+
+func GetLayout(name string) (Layout, error) {
+	layoutsMutex.RLock()
+	defer layoutsMutex.RUnlock()
+
+	if layout, isSet := layouts[strings.ToLower(name)]; isSet {
+		return layout, nil
+	}
+
+	return nil, fmt.Errorf("Keyboard layout '%s' not found", name)
+}
+
+func LayoutNames() (names []string) {
+	layoutsMutex.RLock()
+	defer layoutsMutex.RUnlock()
+
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return
+}
@@ -0,0 +1,57 @@
+package keyboard
+
+// US - the US QWERTY layout
+var US = NewMapLayout("us", usKeys())
+
+func init() {
+	RegisterLayout(US)
+}
+
+func usKeys() map[rune][]string {
+	keys := map[rune][]string{
+		' ':  {"spc"},
+		'\n': {"ret"},
+		'\t': {"tab"},
+	}
+
+	for r := 'a'; r <= 'z'; r++ {
+		keys[r] = []string{string(r)}
+		keys[r-'a'+'A'] = []string{"shift", string(r)}
+	}
+	for r := '0'; r <= '9'; r++ {
+		keys[r] = []string{string(r)}
+	}
+
+	// unshifted and shifted symbol of each key
+	symbols := map[string][2]rune{
+		"1":             {0, '!'},
+		"2":             {0, '@'},
+		"3":             {0, '#'},
+		"4":             {0, '$'},
+		"5":             {0, '%'},
+		"6":             {0, '^'},
+		"7":             {0, '&'},
+		"8":             {0, '*'},
+		"9":             {0, '('},
+		"0":             {0, ')'},
+		"minus":         {'-', '_'},
+		"equal":         {'=', '+'},
+		"bracket_left":  {'[', '{'},
+		"bracket_right": {']', '}'},
+		"backslash":     {'\\', '|'},
+		"semicolon":     {';', ':'},
+		"apostrophe":    {'\'', '"'},
+		"grave_accent":  {'`', '~'},
+		"comma":         {',', '<'},
+		"dot":           {'.', '>'},
+		"slash":         {'/', '?'},
+	}
+	for qcode, chars := range symbols {
+		if chars[0] != 0 {
+			keys[chars[0]] = []string{qcode}
+		}
+		keys[chars[1]] = []string{"shift", qcode}
+	}
+
+	return keys
+}
@@ -0,0 +1,490 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// User - a PVE user, UserID is of the form name@realm. Enable, Expire and
+// Groups are only sent when set (Expire 0 means never, an empty non-nil
+// Groups removes the user from all groups), so an update leaves the unset
+// ones as they are
+type User struct {
+	UserID    string  `json:"userid"`
+	Comment   string  `json:"comment,omitempty"`
+	Email     string  `json:"email,omitempty"`
+	Enable    *bool   `json:"enable,omitempty"`
+	Expire    *int64  `json:"expire,omitempty"`
+	Firstname string  `json:"firstname,omitempty"`
+	Lastname  string  `json:"lastname,omitempty"`
+	Groups    PVEList `json:"groups,omitempty"`
+	Keys      string  `json:"keys,omitempty"`
+}
+
+// PVE returns enable as a 0/1 number
+func (user *User) UnmarshalJSON(b []byte) (err error) {
+	type fields User
+	aux := struct {
+		*fields
+		Enable *PVEBool `json:"enable,omitempty"`
+	}{fields: (*fields)(user)}
+	if err = json.Unmarshal(b, &aux); err == nil {
+		user.Enable = (*bool)(aux.Enable)
+	}
+
+	return
+}
+
+// Params - the user fields that are set as parameters for the create and
+// update endpoints
+func (user User) Params() map[string]interface{} {
+	params := map[string]interface{}{}
+	if user.Enable != nil {
+		params["enable"] = *user.Enable
+	}
+	if user.Expire != nil {
+		params["expire"] = *user.Expire
+	}
+	if user.Groups != nil {
+		params["groups"] = user.Groups.String()
+	}
+
+	optional := map[string]string{
+		"comment":   user.Comment,
+		"email":     user.Email,
+		"firstname": user.Firstname,
+		"lastname":  user.Lastname,
+		"keys":      user.Keys,
+	}
+	for k, v := range optional {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	return params
+}
+
+// user ids are escaped for use in paths, the @ before the realm is allowed
+// in a path segment and PVE takes it as is, only characters like / change
+func userUrl(userid string) string {
+	return "/access/users/" + url.PathEscape(userid)
+}
+
+func GetUserList() (list []User, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/access/users", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func GetUser(userid string) (user *User, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(userUrl(userid), nil, nil); err == nil {
+		user = &User{}
+		if err = DataResponse(resp, user); err == nil {
+			user.UserID = userid
+		}
+	}
+
+	return
+}
+
+// CreateUser - the password is only used for the pve realm, and can be empty
+func CreateUser(user User, password string) (err error) {
+	params := user.Params()
+	params["userid"] = user.UserID
+	if password != "" {
+		params["password"] = password
+	}
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Post("/access/users", nil, nil, &reqbody)
+
+	return
+}
+
+func UpdateUser(user User) (err error) {
+	reqbody := ParamsToBody(user.Params())
+	_, err = GetClient().session.Put(userUrl(user.UserID), nil, nil, &reqbody)
+
+	return
+}
+
+func DeleteUser(userid string) (err error) {
+	_, err = GetClient().session.Delete(userUrl(userid), nil, nil)
+	return
+}
+
+// SetUserEnabled - enable or disable a user without touching the rest of its
+// configuration
+func SetUserEnabled(userid string, enable bool) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"enable": enable})
+	_, err = GetClient().session.Put(userUrl(userid), nil, nil, &reqbody)
+
+	return
+}
+
+// SetUserExpire - expire is an epoch timestamp, 0 means never
+func SetUserExpire(userid string, expire int64) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"expire": expire})
+	_, err = GetClient().session.Put(userUrl(userid), nil, nil, &reqbody)
+
+	return
+}
+
+func ChangeUserPassword(userid string, password string) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"userid": userid, "password": password})
+	olddebug := *Debug
+	*Debug = false // don't share passwords in debug log
+	_, err = GetClient().session.Put("/access/password", nil, nil, &reqbody)
+	*Debug = olddebug
+
+	return
+}
+
+// ApiToken - an API token of a user, privilege separated tokens only get
+// the permissions granted to them through ACLs. Expire and Privsep are only
+// sent when set, PVE separates privileges of new tokens by default
+type ApiToken struct {
+	TokenID string `json:"tokenid"`
+	Comment string `json:"comment,omitempty"`
+	Expire  *int64 `json:"expire,omitempty"`
+	Privsep *bool  `json:"privsep,omitempty"`
+}
+
+// PVE returns privsep as a 0/1 number
+func (token *ApiToken) UnmarshalJSON(b []byte) (err error) {
+	type fields ApiToken
+	aux := struct {
+		*fields
+		Privsep *PVEBool `json:"privsep,omitempty"`
+	}{fields: (*fields)(token)}
+	if err = json.Unmarshal(b, &aux); err == nil {
+		token.Privsep = (*bool)(aux.Privsep)
+	}
+
+	return
+}
+
+// ApiTokenSecret - returned on token creation, the value can't be read again
+type ApiTokenSecret struct {
+	FullTokenID string `json:"full-tokenid"`
+	Value       string `json:"value"`
+}
+
+func (token ApiToken) Params() map[string]interface{} {
+	params := map[string]interface{}{}
+	if token.Expire != nil {
+		params["expire"] = *token.Expire
+	}
+	if token.Privsep != nil {
+		params["privsep"] = *token.Privsep
+	}
+	if token.Comment != "" {
+		params["comment"] = token.Comment
+	}
+
+	return params
+}
+
+func GetApiTokenList(userid string) (list []ApiToken, err error) {
+	var resp *http.Response
+	url := userUrl(userid) + "/token"
+	if resp, err = GetClient().session.Get(url, nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func CreateApiToken(userid string, token ApiToken) (secret *ApiTokenSecret, err error) {
+	reqbody := ParamsToBody(token.Params())
+	url := fmt.Sprintf("%s/token/%s", userUrl(userid), token.TokenID)
+
+	var resp *http.Response
+	olddebug := *Debug
+	*Debug = false // don't share the token secret in debug log
+	resp, err = GetClient().session.Post(url, nil, nil, &reqbody)
+	*Debug = olddebug
+
+	if err == nil {
+		secret = &ApiTokenSecret{}
+		err = DataResponse(resp, secret)
+	}
+
+	return
+}
+
+func UpdateApiToken(userid string, token ApiToken) (err error) {
+	reqbody := ParamsToBody(token.Params())
+	url := fmt.Sprintf("%s/token/%s", userUrl(userid), token.TokenID)
+	_, err = GetClient().session.Put(url, nil, nil, &reqbody)
+
+	return
+}
+
+func DeleteApiToken(userid string, tokenid string) (err error) {
+	url := fmt.Sprintf("%s/token/%s", userUrl(userid), tokenid)
+	_, err = GetClient().session.Delete(url, nil, nil)
+
+	return
+}
+
+// Group - a user group. Membership is managed on the users' side, through
+// User.Groups
+type Group struct {
+	GroupID string
+	Comment string
+	Members PVEList
+}
+
+func (g *Group) UnmarshalJSON(b []byte) (err error) {
+	// the list endpoint names the members "users", the group endpoint
+	// names them "members"
+	var intermediate struct {
+		GroupID string  `json:"groupid"`
+		Comment string  `json:"comment"`
+		Users   PVEList `json:"users"`
+		Members PVEList `json:"members"`
+	}
+
+	if err = json.Unmarshal(b, &intermediate); err == nil {
+		g.GroupID = intermediate.GroupID
+		g.Comment = intermediate.Comment
+		g.Members = intermediate.Members
+		if len(g.Members) == 0 {
+			g.Members = intermediate.Users
+		}
+	}
+
+	return
+}
+
+func GetGroupList() (list []Group, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/access/groups", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func GetGroup(groupid string) (group *Group, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/access/groups/"+groupid, nil, nil); err == nil {
+		group = &Group{}
+		if err = DataResponse(resp, group); err == nil {
+			group.GroupID = groupid
+		}
+	}
+
+	return
+}
+
+func CreateGroup(groupid string, comment string) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"groupid": groupid, "comment": comment})
+	_, err = GetClient().session.Post("/access/groups", nil, nil, &reqbody)
+
+	return
+}
+
+func UpdateGroup(groupid string, comment string) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"comment": comment})
+	_, err = GetClient().session.Put("/access/groups/"+groupid, nil, nil, &reqbody)
+
+	return
+}
+
+func DeleteGroup(groupid string) (err error) {
+	_, err = GetClient().session.Delete("/access/groups/"+groupid, nil, nil)
+	return
+}
+
+// Role - a named list of privileges, special roles are built in
+type Role struct {
+	RoleID  string  `json:"roleid"`
+	Privs   PVEList `json:"privs"`
+	Special PVEBool `json:"special"`
+}
+
+func GetRoleList() (list []Role, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/access/roles", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func GetRole(roleid string) (role *Role, err error) {
+	// the role endpoint returns the privileges as a map to 1
+	var (
+		resp  *http.Response
+		privs map[string]interface{}
+	)
+
+	if resp, err = GetClient().session.Get("/access/roles/"+roleid, nil, nil); err == nil {
+		if err = DataResponse(resp, &privs); err == nil {
+			role = &Role{RoleID: roleid, Privs: PVEList{}}
+			for priv := range privs {
+				role.Privs = append(role.Privs, priv)
+			}
+			sort.Strings(role.Privs)
+		}
+	}
+
+	return
+}
+
+func CreateRole(role Role) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"roleid": role.RoleID, "privs": role.Privs.String()})
+	_, err = GetClient().session.Post("/access/roles", nil, nil, &reqbody)
+
+	return
+}
+
+// UpdateRole - set the role privileges, or add them to the existing ones
+// if add is true
+func UpdateRole(role Role, add bool) (err error) {
+	params := map[string]interface{}{"privs": role.Privs.String()}
+	if add {
+		params["append"] = true
+	}
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Put("/access/roles/"+role.RoleID, nil, nil, &reqbody)
+
+	return
+}
+
+func DeleteRole(roleid string) (err error) {
+	_, err = GetClient().session.Delete("/access/roles/"+roleid, nil, nil)
+	return
+}
+
+// Realm - an authentication domain (pam, pve, ldap, ad, openid)
+type Realm struct {
+	Realm   string  `json:"realm"`
+	Type    string  `json:"type"`
+	Comment string  `json:"comment,omitempty"`
+	Default PVEBool `json:"default"`
+	Tfa     string  `json:"tfa,omitempty"`
+}
+
+func GetRealmList() (list []Realm, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/access/domains", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+// GetRealmConfig - the configuration keys depend on the realm type
+func GetRealmConfig(realm string) (config map[string]interface{}, err error) {
+	var resp map[string]interface{}
+	if err = GetClient().GetJsonRetryable("/access/domains/"+realm, &resp, 3); err == nil {
+		if resp["data"] == nil {
+			return nil, errors.New("Realm config could not be read")
+		}
+		config = resp["data"].(map[string]interface{})
+	}
+
+	return
+}
+
+func CreateRealm(realm string, realmType string, realmParams map[string]interface{}) (err error) {
+	params := map[string]interface{}{"realm": realm, "type": realmType}
+	for k, v := range realmParams {
+		params[k] = v
+	}
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Post("/access/domains", nil, nil, &reqbody)
+
+	return
+}
+
+func UpdateRealm(realm string, realmParams map[string]interface{}) (err error) {
+	reqbody := ParamsToBody(realmParams)
+	_, err = GetClient().session.Put("/access/domains/"+realm, nil, nil, &reqbody)
+
+	return
+}
+
+func DeleteRealm(realm string) (err error) {
+	_, err = GetClient().session.Delete("/access/domains/"+realm, nil, nil)
+	return
+}
+
+// ACLEntry - a role granted on a path to a user, group or API token
+// Type is one of "user", "group" or "token", UGID is the matching id
+type ACLEntry struct {
+	Path      string  `json:"path"`
+	Type      string  `json:"type"`
+	UGID      string  `json:"ugid"`
+	RoleID    string  `json:"roleid"`
+	Propagate PVEBool `json:"propagate"`
+}
+
+func (acl ACLEntry) params() (params map[string]interface{}, err error) {
+	params = map[string]interface{}{
+		"path":      acl.Path,
+		"roles":     acl.RoleID,
+		"propagate": acl.Propagate,
+	}
+
+	switch acl.Type {
+	case "user":
+		params["users"] = acl.UGID
+	case "group":
+		params["groups"] = acl.UGID
+	case "token":
+		params["tokens"] = acl.UGID
+	default:
+		return nil, fmt.Errorf("Invalid ACL type '%s'", acl.Type)
+	}
+
+	return
+}
+
+func GetACL() (list []ACLEntry, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/access/acl", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func AddACL(acl ACLEntry) (err error) {
+	var params map[string]interface{}
+	if params, err = acl.params(); err != nil {
+		return
+	}
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Put("/access/acl", nil, nil, &reqbody)
+
+	return
+}
+
+func DeleteACL(acl ACLEntry) (err error) {
+	var params map[string]interface{}
+	if params, err = acl.params(); err != nil {
+		return
+	}
+	params["delete"] = true
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Put("/access/acl", nil, nil, &reqbody)
+
+	return
+}
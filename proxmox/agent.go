@@ -0,0 +1,421 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type AgentNetworkInterface struct {
+	MACAddress  string
+	IPAddresses []net.IP
+	Name        string
+	Statistics  map[string]int64
+}
+
+func (a *AgentNetworkInterface) UnmarshalJSON(b []byte) (err error) {
+	var intermediate struct {
+		HardwareAddress string `json:"hardware-address"`
+		IPAddresses     []struct {
+			IPAddress     string `json:"ip-address"`
+			IPAddressType string `json:"ip-address-type"`
+			Prefix        int    `json:"prefix"`
+		} `json:"ip-addresses"`
+		Name       string           `json:"name"`
+		Statistics map[string]int64 `json:"statistics"`
+	}
+
+	if err = json.Unmarshal(b, &intermediate); err == nil {
+		a.IPAddresses = make([]net.IP, len(intermediate.IPAddresses))
+		for idx, ip := range intermediate.IPAddresses {
+			a.IPAddresses[idx] = net.ParseIP(ip.IPAddress)
+			if a.IPAddresses[idx] == nil {
+				return fmt.Errorf("Could not parse %s as IP", ip.IPAddress)
+			}
+		}
+		a.MACAddress = intermediate.HardwareAddress
+		a.Name = intermediate.Name
+		a.Statistics = intermediate.Statistics
+	}
+
+	return
+}
+
+type AgentOSInfo struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	PrettyName    string `json:"pretty-name"`
+	Version       string `json:"version"`
+	VersionID     string `json:"version-id"`
+	Variant       string `json:"variant"`
+	VariantID     string `json:"variant-id"`
+	KernelRelease string `json:"kernel-release"`
+	KernelVersion string `json:"kernel-version"`
+	Machine       string `json:"machine"`
+}
+
+type AgentUser struct {
+	User string `json:"user"`
+	// Windows only
+	Domain string `json:"domain,omitempty"`
+	// seconds since the epoch, with microseconds
+	LoginTime float64 `json:"login-time"`
+}
+
+func (u AgentUser) Login() time.Time {
+	return time.Unix(0, int64(u.LoginTime*1e9))
+}
+
+type AgentFilesystem struct {
+	Name       string `json:"name"`
+	Mountpoint string `json:"mountpoint"`
+	Type       string `json:"type"`
+	UsedBytes  int64  `json:"used-bytes,omitempty"`
+	TotalBytes int64  `json:"total-bytes,omitempty"`
+	Disk       []struct {
+		Serial  string `json:"serial,omitempty"`
+		Dev     string `json:"dev,omitempty"`
+		BusType string `json:"bus-type"`
+		Bus     int    `json:"bus"`
+		Target  int    `json:"target"`
+		Unit    int    `json:"unit"`
+	} `json:"disk"`
+}
+
+type AgentVCPU struct {
+	LogicalID  int  `json:"logical-id"`
+	Online     bool `json:"online"`
+	CanOffline bool `json:"can-offline,omitempty"`
+}
+
+type AgentMemoryBlock struct {
+	PhysIndex  int64 `json:"phys-index"`
+	Online     bool  `json:"online"`
+	CanOffline bool  `json:"can-offline,omitempty"`
+}
+
+type AgentFstrimResult struct {
+	Path    string `json:"path"`
+	Trimmed int64  `json:"trimmed,omitempty"`
+	Minimum int64  `json:"minimum,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AgentFile - the result of reading a file through the agent, Truncated is
+// set when the file was larger than what PVE reads (16MiB)
+type AgentFile struct {
+	Content   string  `json:"content"`
+	Truncated PVEBool `json:"truncated,omitempty"`
+}
+
+// agentUrl - the agent endpoints are only available for VMs
+func (vm *Vm) agentUrl(command string) (url string, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	if vm.vmtype != "qemu" {
+		return "", errors.New("The guest agent is only available for VMs")
+	}
+
+	return fmt.Sprintf("/nodes/%s/%s/%d/agent/%s", vm.node.name, vm.vmtype, vm.id, command), nil
+}
+
+// agentGet - run an agent command of the GET kind, decoding its result into
+// v (for the commands with an envelope as {"data": {"result": ...}})
+func (vm *Vm) agentGet(command string, params *url.Values, v interface{}) (err error) {
+	var cmdUrl string
+	if cmdUrl, err = vm.agentUrl(command); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(cmdUrl, params, nil); err == nil {
+		err = TypedResponse(resp, v)
+	}
+
+	return
+}
+
+// agentPost - run an agent command of the POST kind, with v nil the result is
+// discarded
+func (vm *Vm) agentPost(command string, params map[string]interface{}, v interface{}) (err error) {
+	var cmdUrl string
+	if cmdUrl, err = vm.agentUrl(command); err != nil {
+		return
+	}
+
+	var reqbody *[]byte
+	if params != nil {
+		body := ParamsToBody(params)
+		reqbody = &body
+	}
+
+	var resp *http.Response
+	if resp, err = GetClient().session.Post(cmdUrl, nil, nil, reqbody); err == nil {
+		if v != nil {
+			err = TypedResponse(resp, v)
+		} else {
+			resp.Body.Close()
+		}
+	}
+
+	return
+}
+
+func (vm *Vm) GetAgentNetworkInterfaces() (ifs []AgentNetworkInterface, err error) {
+	err = vm.agentGet("network-get-interfaces", nil, &ifs)
+	return
+}
+
+// AgentPing - nil if the agent is running and answering
+func (vm *Vm) AgentPing() error {
+	return vm.agentPost("ping", nil, nil)
+}
+
+func (vm *Vm) GetAgentOSInfo() (info AgentOSInfo, err error) {
+	err = vm.agentGet("get-osinfo", nil, &info)
+	return
+}
+
+func (vm *Vm) GetAgentHostName() (hostname string, err error) {
+	var result struct {
+		HostName string `json:"host-name"`
+	}
+	if err = vm.agentGet("get-host-name", nil, &result); err == nil {
+		hostname = result.HostName
+	}
+
+	return
+}
+
+func (vm *Vm) GetAgentUsers() (users []AgentUser, err error) {
+	err = vm.agentGet("get-users", nil, &users)
+	return
+}
+
+// GetAgentTime - the guest clock
+func (vm *Vm) GetAgentTime() (guestTime time.Time, err error) {
+	var nanoseconds int64
+	if err = vm.agentGet("get-time", nil, &nanoseconds); err == nil {
+		guestTime = time.Unix(0, nanoseconds)
+	}
+
+	return
+}
+
+func (vm *Vm) GetAgentFsInfo() (filesystems []AgentFilesystem, err error) {
+	err = vm.agentGet("get-fsinfo", nil, &filesystems)
+	return
+}
+
+func (vm *Vm) GetAgentVCPUs() (vcpus []AgentVCPU, err error) {
+	err = vm.agentGet("get-vcpus", nil, &vcpus)
+	return
+}
+
+func (vm *Vm) GetAgentMemoryBlocks() (blocks []AgentMemoryBlock, err error) {
+	err = vm.agentGet("get-memory-blocks", nil, &blocks)
+	return
+}
+
+// AgentFsFreeze - freeze the guest filesystems, returns how many were frozen
+func (vm *Vm) AgentFsFreeze() (frozen int, err error) {
+	err = vm.agentPost("fsfreeze-freeze", nil, &frozen)
+	return
+}
+
+// AgentFsThaw - thaw the guest filesystems, returns how many were thawed
+func (vm *Vm) AgentFsThaw() (thawed int, err error) {
+	err = vm.agentPost("fsfreeze-thaw", nil, &thawed)
+	return
+}
+
+// AgentFsFreezeStatus - "frozen" or "thawed"
+func (vm *Vm) AgentFsFreezeStatus() (status string, err error) {
+	err = vm.agentPost("fsfreeze-status", nil, &status)
+	return
+}
+
+// AgentFstrim - discard the unused blocks of the guest filesystems
+func (vm *Vm) AgentFstrim() (paths []AgentFstrimResult, err error) {
+	var result struct {
+		Paths []AgentFstrimResult `json:"paths"`
+	}
+	if err = vm.agentPost("fstrim", nil, &result); err == nil {
+		paths = result.Paths
+	}
+
+	return
+}
+
+// AgentShutdown - shut down from inside the guest, without waiting for it
+func (vm *Vm) AgentShutdown() error {
+	return vm.agentPost("shutdown", nil, nil)
+}
+
+func (vm *Vm) AgentSuspendDisk() error {
+	return vm.agentPost("suspend-disk", nil, nil)
+}
+
+func (vm *Vm) AgentSuspendRam() error {
+	return vm.agentPost("suspend-ram", nil, nil)
+}
+
+// AgentSetUserPassword - with crypted, password is already encrypted as
+// expected by the guest (crypt(3) for Linux)
+func (vm *Vm) AgentSetUserPassword(username string, password string, crypted bool) (err error) {
+	params := map[string]interface{}{
+		"username": username,
+		"password": password,
+	}
+	if crypted {
+		params["crypted"] = true
+	}
+
+	olddebug := *Debug
+	*Debug = false // don't share passwords in debug log
+	err = vm.agentPost("set-user-password", params, nil)
+	*Debug = olddebug
+
+	return
+}
+
+// AgentFileRead - read a file in the guest
+func (vm *Vm) AgentFileRead(file string) (content AgentFile, err error) {
+	params := &url.Values{}
+	params.Set("file", file)
+
+	var fileUrl string
+	if fileUrl, err = vm.agentUrl("file-read"); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(fileUrl, params, nil); err == nil {
+		err = DataResponse(resp, &content)
+	}
+
+	return
+}
+
+// AgentFileWrite - write content to a file in the guest, replacing it
+func (vm *Vm) AgentFileWrite(file string, content []byte) (err error) {
+	var fileUrl string
+	if fileUrl, err = vm.agentUrl("file-write"); err != nil {
+		return
+	}
+
+	// sent already encoded, so binary content goes through untouched
+	reqbody := ParamsToBody(map[string]interface{}{
+		"file":    file,
+		"content": base64.StdEncoding.EncodeToString(content),
+		"encode":  false,
+	})
+	_, err = GetClient().session.Post(fileUrl, nil, nil, &reqbody)
+
+	return
+}
+
+// AgentExecStatus - the state of a command started through the agent, the
+// output is only set once it exited and comes already decoded by PVE
+type AgentExecStatus struct {
+	Exited       PVEBool `json:"exited"`
+	ExitCode     int     `json:"exitcode"`
+	Signal       int     `json:"signal,omitempty"`
+	Stdout       string  `json:"out-data,omitempty"`
+	Stderr       string  `json:"err-data,omitempty"`
+	OutTruncated PVEBool `json:"out-truncated,omitempty"`
+	ErrTruncated PVEBool `json:"err-truncated,omitempty"`
+}
+
+// AgentExecInterval - time between exec status checks
+const AgentExecInterval = time.Second
+
+// AgentExecStart - start a command in the guest, returns its pid
+// PVE doesn't pass an environment to the agent, so env is set by running
+// the command through env(1), which only works on Unix guests
+func (vm *Vm) AgentExecStart(cmd []string, stdin string, env map[string]string) (pid int, err error) {
+	if len(cmd) == 0 {
+		return 0, errors.New("No command to execute")
+	}
+
+	if len(env) > 0 {
+		vars := []string{}
+		for k, v := range env {
+			vars = append(vars, k+"="+v)
+		}
+		sort.Strings(vars)
+		cmd = append(append([]string{"env"}, vars...), cmd...)
+	}
+
+	params := map[string]interface{}{"command": cmd}
+	if stdin != "" {
+		params["input-data"] = stdin
+	}
+
+	var execUrl string
+	if execUrl, err = vm.agentUrl("exec"); err != nil {
+		return
+	}
+
+	reqbody := ParamsToBody(params)
+
+	var resp *http.Response
+	if resp, err = GetClient().session.Post(execUrl, nil, nil, &reqbody); err == nil {
+		var result struct {
+			Pid int `json:"pid"`
+		}
+		if err = DataResponse(resp, &result); err == nil {
+			pid = result.Pid
+		}
+	}
+
+	return
+}
+
+func (vm *Vm) AgentExecStatus(pid int) (status AgentExecStatus, err error) {
+	params := &url.Values{}
+	params.Set("pid", strconv.Itoa(pid))
+
+	var statusUrl string
+	if statusUrl, err = vm.agentUrl("exec-status"); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(statusUrl, params, nil); err == nil {
+		err = DataResponse(resp, &status)
+	}
+
+	return
+}
+
+// AgentExec - run a command in the guest and wait for it to exit, up to the
+// ctx deadline. The command keeps running in the guest if ctx is done first
+func (vm *Vm) AgentExec(ctx context.Context, cmd []string, stdin string, env map[string]string) (status AgentExecStatus, err error) {
+	var pid int
+	if pid, err = vm.AgentExecStart(cmd, stdin, env); err != nil {
+		return
+	}
+
+	for {
+		if status, err = vm.AgentExecStatus(pid); err != nil || status.Exited {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("Command %d didn't exit: %v", pid, ctx.Err())
+		case <-time.After(AgentExecInterval):
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// VzdumpOptions - options for one-shot backups and backup jobs
+type VzdumpOptions struct {
+	// snapshot, suspend or stop
+	Mode string `json:"mode,omitempty"`
+	// 0, 1, gzip, lzo or zstd
+	Compress string `json:"compress,omitempty"`
+	Storage  string `json:"storage,omitempty"`
+	// always or failure
+	MailNotification string `json:"mailnotification,omitempty"`
+	MailTo           string `json:"mailto,omitempty"`
+	// can use the {{cluster}}, {{guestname}}, {{node}} and {{vmid}} variables
+	NotesTemplate string  `json:"notes-template,omitempty"`
+	Protected     PVEBool `json:"protected,omitempty"`
+	Remove        PVEBool `json:"remove,omitempty"`
+	Bwlimit       int     `json:"bwlimit,omitempty"`
+}
+
+func (opts VzdumpOptions) Validate() error {
+	if opts.Mode != "" && !inArray([]string{"snapshot", "suspend", "stop"}, opts.Mode) {
+		return fmt.Errorf("Invalid backup mode '%s'", opts.Mode)
+	}
+	if opts.Compress != "" && !inArray([]string{"0", "1", "gzip", "lzo", "zstd"}, opts.Compress) {
+		return fmt.Errorf("Invalid backup compression '%s'", opts.Compress)
+	}
+	if opts.MailNotification != "" && !inArray([]string{"always", "failure"}, opts.MailNotification) {
+		return fmt.Errorf("Invalid mail notification '%s'", opts.MailNotification)
+	}
+	if opts.Protected && opts.Storage == "" {
+		return errors.New("Protected backups need a target storage")
+	}
+
+	return nil
+}
+
+// Params - the options as parameters for the vzdump and backup job endpoints
+func (opts VzdumpOptions) Params() map[string]interface{} {
+	params := map[string]interface{}{}
+
+	optional := map[string]string{
+		"mode":             opts.Mode,
+		"compress":         opts.Compress,
+		"storage":          opts.Storage,
+		"mailnotification": opts.MailNotification,
+		"mailto":           opts.MailTo,
+		"notes-template":   opts.NotesTemplate,
+	}
+	for k, v := range optional {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	if opts.Protected {
+		params["protected"] = true
+	}
+	if opts.Remove {
+		params["remove"] = true
+	}
+	if opts.Bwlimit > 0 {
+		params["bwlimit"] = opts.Bwlimit
+	}
+
+	return params
+}
+
+// BackupJob - a scheduled backup at /cluster/backup
+// Vmid is a comma separated list, alternatively All or Pool select the guests
+// note that the zero value of Enabled creates a disabled job
+type BackupJob struct {
+	ID       string  `json:"id,omitempty"`
+	Schedule string  `json:"schedule"`
+	Enabled  PVEBool `json:"enabled"`
+	Node     string  `json:"node,omitempty"`
+	Vmid     string  `json:"vmid,omitempty"`
+	All      PVEBool `json:"all,omitempty"`
+	Pool     string  `json:"pool,omitempty"`
+	Exclude  string  `json:"exclude,omitempty"`
+	Comment  string  `json:"comment,omitempty"`
+	VzdumpOptions
+}
+
+func (job BackupJob) Params() map[string]interface{} {
+	params := job.VzdumpOptions.Params()
+	params["schedule"] = job.Schedule
+	params["enabled"] = job.Enabled
+
+	optional := map[string]string{
+		"node":    job.Node,
+		"vmid":    job.Vmid,
+		"pool":    job.Pool,
+		"exclude": job.Exclude,
+		"comment": job.Comment,
+	}
+	for k, v := range optional {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	if job.All {
+		params["all"] = true
+	}
+
+	return params
+}
+
+func GetBackupJobList() (list []BackupJob, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/cluster/backup", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func GetBackupJob(id string) (job *BackupJob, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get("/cluster/backup/"+id, nil, nil); err == nil {
+		job = &BackupJob{}
+		err = DataResponse(resp, job)
+	}
+
+	return
+}
+
+// CreateBackupJob - PVE generates the job id when it's empty
+func CreateBackupJob(job BackupJob) (err error) {
+	if err = job.Validate(); err != nil {
+		return
+	}
+
+	params := job.Params()
+	if job.ID != "" {
+		params["id"] = job.ID
+	}
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Post("/cluster/backup", nil, nil, &reqbody)
+
+	return
+}
+
+func UpdateBackupJob(job BackupJob) (err error) {
+	if job.ID == "" {
+		return errors.New("Backup job id is required")
+	}
+	if err = job.Validate(); err != nil {
+		return
+	}
+
+	reqbody := ParamsToBody(job.Params())
+	_, err = GetClient().session.Put("/cluster/backup/"+job.ID, nil, nil, &reqbody)
+
+	return
+}
+
+func DeleteBackupJob(id string) (err error) {
+	_, err = GetClient().session.Delete("/cluster/backup/"+id, nil, nil)
+	return
+}
+
+// StorageErrors - errors by storage name, of the storages that failed while
+// the others went on
+type StorageErrors map[string]error
+
+func (e StorageErrors) Error() string {
+	names := []string{}
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := []string{}
+	for _, name := range names {
+		errs = append(errs, fmt.Sprintf("%s: %v", name, e[name]))
+	}
+	return "Error listing storages: " + strings.Join(errs, "; ")
+}
+
+// GetBackupList - backup volumes of the guest in every storage enabled for
+// backups on its node, newest first. Storages that fail to list are skipped,
+// then err is a StorageErrors and list has the backups of the rest
+func (vm *Vm) GetBackupList() (list []StorageVolume, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	var storages []interface{}
+	if storages, err = vm.node.GetStorageList("backup"); err != nil {
+		return
+	}
+
+	storageErrors := StorageErrors{}
+	for _, s := range storages {
+		storage := NewStorage(s.(map[string]interface{})["storage"].(string))
+
+		volumes, contentErr := storage.GetContent(vm.node, "backup", vm.id)
+		if contentErr != nil {
+			storageErrors[storage.name] = contentErr
+			continue
+		}
+		list = append(list, volumes...)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Ctime > list[j].Ctime })
+
+	if len(storageErrors) > 0 {
+		err = storageErrors
+	}
+
+	return
+}
+
+// storage content urls take the full volume id, escaped
+func volumeUrl(node *Node, volid string) string {
+	storageName := strings.SplitN(volid, ":", 2)[0]
+	return fmt.Sprintf("/nodes/%s/storage/%s/content/%s", node.name, storageName, url.PathEscape(volid))
+}
+
+// DeleteBackup - remove a backup volume, protected backups can't be removed
+func (node *Node) DeleteBackup(volid string) (exitStatus string, err error) {
+	var taskResponse map[string]interface{}
+	if _, err = GetClient().session.RequestJSON("DELETE", volumeUrl(node, volid), nil, nil, nil, &taskResponse); err == nil {
+		exitStatus, err = GetClient().WaitForCompletion(taskResponse)
+	}
+
+	return
+}
+
+// SetBackupProtected - protect a backup from removal and pruning
+func (node *Node) SetBackupProtected(volid string, protected bool) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"protected": protected})
+	_, err = GetClient().session.Put(volumeUrl(node, volid), nil, nil, &reqbody)
+
+	return
+}
+
+// SetBackupNotes - set the notes shown for a backup
+func (node *Node) SetBackupNotes(volid string, notes string) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"notes": notes})
+	_, err = GetClient().session.Put(volumeUrl(node, volid), nil, nil, &reqbody)
+
+	return
+}
@@ -0,0 +1,284 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BulkFilter - selects guests from the cluster resources, empty fields match
+// everything. Templates are never selected
+type BulkFilter struct {
+	Node string `json:"node,omitempty"`
+	// qemu or lxc
+	Type   string `json:"type,omitempty"`
+	Pool   string `json:"pool,omitempty"`
+	Status string `json:"status,omitempty"`
+	// guests having all these tags
+	Tags  []string `json:"tags,omitempty"`
+	Vmids []int    `json:"vmids,omitempty"`
+}
+
+func (filter BulkFilter) match(vmInfo map[string]interface{}) bool {
+	if fmt.Sprint(vmInfo["template"]) == "1" {
+		return false
+	}
+
+	fields := map[string]string{
+		"node":   filter.Node,
+		"type":   filter.Type,
+		"pool":   filter.Pool,
+		"status": filter.Status,
+	}
+	for k, v := range fields {
+		if v != "" && vmInfo[k] != v {
+			return false
+		}
+	}
+
+	if len(filter.Vmids) > 0 {
+		vmid := int(vmInfo["vmid"].(float64))
+		found := false
+		for _, id := range filter.Vmids {
+			found = found || id == vmid
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(filter.Tags) > 0 {
+		tags, _ := vmInfo["tags"].(string)
+		vmTags := strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == ',' || r == ' ' })
+		for _, tag := range filter.Tags {
+			if !inArray(vmTags, tag) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// FindVms - the guests matching filter, by vmid
+func FindVms(filter BulkFilter) (vms []*Vm, err error) {
+	var list []interface{}
+	if list, err = GetVmList(); err != nil {
+		return
+	}
+
+	for i := range list {
+		vmInfo := list[i].(map[string]interface{})
+		if filter.match(vmInfo) {
+			vm := NewVm(int(vmInfo["vmid"].(float64)))
+			vm.node = NewNode(vmInfo["node"].(string))
+			vm.vmtype = vmInfo["type"].(string)
+			vms = append(vms, vm)
+		}
+	}
+
+	sort.Slice(vms, func(i, j int) bool { return vms[i].id < vms[j].id })
+
+	return
+}
+
+// BulkOperation - what to do with each guest of a bulk run, only the options
+// of the chosen action are used
+type BulkOperation struct {
+	// start, shutdown, stop, migrate, snapshot or backup
+	Action   string          `json:"action"`
+	Migrate  MigrateOptions  `json:"migrate,omitempty"`
+	Snapshot SnapshotOptions `json:"snapshot,omitempty"`
+	Backup   VzdumpOptions   `json:"backup,omitempty"`
+}
+
+func (op BulkOperation) run(vm *Vm) (exitStatus string, err error) {
+	switch op.Action {
+	case "start":
+		return vm.Start()
+	case "shutdown":
+		return vm.Shutdown()
+	case "stop":
+		return vm.Stop()
+	case "migrate":
+		return vm.Migrate(op.Migrate)
+	case "snapshot":
+		return vm.CreateSnapshot(op.Snapshot)
+	case "backup":
+		return vm.CreateBackup(op.Backup)
+	}
+
+	return "", fmt.Errorf("Unknown bulk action '%s'", op.Action)
+}
+
+// stopping reverses the startup order
+func (op BulkOperation) stopping() bool {
+	return op.Action == "shutdown" || op.Action == "stop"
+}
+
+func (op BulkOperation) Validate() error {
+	switch op.Action {
+	case "start", "shutdown", "stop":
+	case "migrate":
+		if op.Migrate.Target == "" {
+			return errors.New("Bulk migrations need a target node")
+		}
+	case "snapshot":
+		if op.Snapshot.Name == "" {
+			return errors.New("Bulk snapshots need a snapshot name")
+		}
+	case "backup":
+		return op.Backup.Validate()
+	default:
+		return fmt.Errorf("Unknown bulk action '%s'", op.Action)
+	}
+
+	return nil
+}
+
+// BulkOptions - concurrency and ordering of a bulk run
+// Workers is the total of guests processed at once, PerNode the limit for
+// the guests of a single node, zero means no limit. With Ordered, guests are
+// processed in groups of the same startup order, one group after the other:
+// ascending to start (guests without order last), descending otherwise
+type BulkOptions struct {
+	Workers int  `json:"workers,omitempty"`
+	PerNode int  `json:"pernode,omitempty"`
+	Ordered bool `json:"ordered,omitempty"`
+}
+
+// BulkResult - the outcome of the operation on one guest
+type BulkResult struct {
+	Vmid       int       `json:"vmid"`
+	Node       string    `json:"node"`
+	ExitStatus string    `json:"exitstatus"`
+	Error      string    `json:"error,omitempty"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+
+	Err error `json:"-"`
+}
+
+// bulkGroups - the guests split in the groups to run one after the other
+func bulkGroups(vms []*Vm, op BulkOperation, opts BulkOptions) (groups [][]int, err error) {
+	if !opts.Ordered {
+		all := []int{}
+		for i := range vms {
+			all = append(all, i)
+		}
+		return [][]int{all}, nil
+	}
+
+	byOrder := map[int][]int{}
+	unordered := []int{}
+	for i, vm := range vms {
+		so, orderErr := vm.GetStartupOrder()
+		if orderErr != nil {
+			return nil, fmt.Errorf("Error reading the startup order of %d: %v", vm.id, orderErr)
+		}
+		if so.Order != nil {
+			byOrder[*so.Order] = append(byOrder[*so.Order], i)
+		} else {
+			unordered = append(unordered, i)
+		}
+	}
+
+	orders := []int{}
+	for order := range byOrder {
+		orders = append(orders, order)
+	}
+	sort.Ints(orders)
+
+	for _, order := range orders {
+		groups = append(groups, byOrder[order])
+	}
+	if len(unordered) > 0 {
+		groups = append(groups, unordered)
+	}
+
+	if op.stopping() {
+		for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+			groups[i], groups[j] = groups[j], groups[i]
+		}
+	}
+
+	return
+}
+
+// RunBulk - run the operation on all the guests, the report has a result
+// per guest in the same order as vms. err is only set when the run couldn't
+// start, failures of single guests are in their result
+func RunBulk(vms []*Vm, op BulkOperation, opts BulkOptions) (report []BulkResult, err error) {
+	if err = op.Validate(); err != nil {
+		return
+	}
+
+	for _, vm := range vms {
+		if err = vm.Check(); err != nil {
+			return
+		}
+	}
+
+	var groups [][]int
+	if groups, err = bulkGroups(vms, op, opts); err != nil {
+		return
+	}
+
+	report = make([]BulkResult, len(vms))
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = len(vms)
+	}
+	slots := make(chan struct{}, workers)
+
+	var mutex sync.Mutex
+	nodeSlots := map[string]chan struct{}{}
+	nodeSlot := func(node string) chan struct{} {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if _, isSet := nodeSlots[node]; !isSet {
+			nodeSlots[node] = make(chan struct{}, opts.PerNode)
+		}
+		return nodeSlots[node]
+	}
+
+	for _, group := range groups {
+		var wg sync.WaitGroup
+
+		for _, i := range group {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				vm := vms[i]
+
+				// node slot first, so the workers are not held by guests
+				// waiting for their node
+				if opts.PerNode > 0 {
+					slot := nodeSlot(vm.node.name)
+					slot <- struct{}{}
+					defer func() { <-slot }()
+				}
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				result := BulkResult{Vmid: vm.id, Node: vm.node.name, Started: time.Now()}
+				result.ExitStatus, result.Err = op.run(vm)
+				result.Finished = time.Now()
+				if result.Err != nil {
+					result.Error = result.Err.Error()
+				}
+
+				report[i] = result
+			}(i)
+		}
+
+		wg.Wait()
+	}
+
+	return
+}
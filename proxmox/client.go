@@ -6,7 +6,6 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"regexp"
 	"time"
@@ -18,6 +17,9 @@ type Client struct {
 	ApiUrl   string
 	Username string
 	Password string
+
+	// check the privileges needed before operations that support it
+	CheckPrivileges bool
 }
 
 func NewClient(apiUrl string, hclient *http.Client, tls *tls.Config) (client *Client, err error) {
@@ -86,26 +88,8 @@ func (c *Client) GetTaskExitstatus(taskUpid string) (exitStatus interface{}, err
 // WaitForCompletion - poll the API for task completion
 func (c *Client) WaitForCompletion(taskResponse map[string]interface{}) (waitExitStatus string, err error) {
 	if taskResponse["errors"] != nil {
-		return taskResponse["errors"].(string), errors.New("Error reponse")
-	}
-	if taskResponse["data"] == nil {
-		return "", nil
-	}
-	waited := 0
-	taskUpid := taskResponse["data"].(string)
-	for waited < TaskTimeout {
-		exitStatus, statErr := c.GetTaskExitstatus(taskUpid)
-		if statErr != nil {
-			if statErr != io.ErrUnexpectedEOF { // don't give up on ErrUnexpectedEOF
-				return "", statErr
-			}
-		}
-		if exitStatus != nil {
-			waitExitStatus = exitStatus.(string)
-			return
-		}
-		time.Sleep(TaskStatusCheckInterval * time.Second)
-		waited = waited + TaskStatusCheckInterval
+		return fmt.Sprint(taskResponse["errors"]), errors.New("Error reponse")
 	}
-	return "", errors.New("Wait timeout for:" + taskUpid)
+
+	return waitTask(NewTaskFromResponse(taskResponse))
 }
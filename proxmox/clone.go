@@ -0,0 +1,281 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CloneOptions - options to clone a guest
+// Linked clones (Full unset) need a template source, and are created on the
+// storage of the template disks, so Storage and Format only apply to full
+// clones
+type CloneOptions struct {
+	Full bool `json:"full,omitempty"`
+	// node to create the clone on, the source node if empty
+	Target  string `json:"target,omitempty"`
+	Storage string `json:"storage,omitempty"`
+	// raw, qcow2 or vmdk, VMs only
+	Format string `json:"format,omitempty"`
+	Pool   string `json:"pool,omitempty"`
+	// name for VMs, hostname for CTs
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// clone from this snapshot instead of the current state
+	Snapname string `json:"snapname,omitempty"`
+	Bwlimit  int    `json:"bwlimit,omitempty"`
+}
+
+// storage types that can hold linked clones, the file based ones only of
+// qcow2 disks
+var (
+	linkedCloneStorageTypes     = []string{"dir", "nfs", "cifs", "glusterfs", "lvmthin", "zfspool", "rbd"}
+	linkedCloneFileStorageTypes = []string{"dir", "nfs", "cifs", "glusterfs"}
+)
+
+var rxCloneableDisk = regexp.MustCompile(`^((ide|sata|scsi|virtio|efidisk|tpmstate|mp)\d+|rootfs)$`)
+
+// diskVolumes - the volume of each disk of a guest config, as
+// "storage:file", CD-ROMs, unused and passed through volumes excluded
+func diskVolumes(vmConfig map[string]interface{}) map[string]string {
+	volumes := map[string]string{}
+
+	for key, value := range vmConfig {
+		conf, isString := value.(string)
+		if !isString || !rxCloneableDisk.MatchString(key) || strings.Contains(conf, "media=cdrom") {
+			continue
+		}
+
+		volume := strings.SplitN(conf, ",", 2)[0]
+		if volume = strings.TrimPrefix(volume, "volume="); strings.Contains(volume, ":") {
+			volumes[key] = volume
+		}
+	}
+
+	return volumes
+}
+
+// diskStorages - the storage of each disk of a guest config, see diskVolumes
+func diskStorages(vmConfig map[string]interface{}) map[string]string {
+	storages := map[string]string{}
+	for key, volume := range diskVolumes(vmConfig) {
+		storages[key] = strings.SplitN(volume, ":", 2)[0]
+	}
+
+	return storages
+}
+
+// Validate - check the options are usable to clone source
+func (opts CloneOptions) Validate(source *Vm) (err error) {
+	var vmConfig map[string]interface{}
+	if vmConfig, err = opts.sourceConfig(source); err != nil {
+		return
+	}
+
+	return opts.validate(source, vmConfig)
+}
+
+// sourceConfig - the config of source when validate or needsMigration need
+// it, so it's read once for both
+func (opts CloneOptions) sourceConfig(source *Vm) (vmConfig map[string]interface{}, err error) {
+	if !opts.Full || (opts.Target != "" && opts.Target != source.node.name) {
+		vmConfig, err = source.GetConfig()
+	}
+
+	return
+}
+
+// validate - Validate with the source config read by sourceConfig
+func (opts CloneOptions) validate(source *Vm, vmConfig map[string]interface{}) (err error) {
+	if opts.Format != "" && !inArray([]string{"raw", "qcow2", "vmdk"}, opts.Format) {
+		return fmt.Errorf("Invalid clone format '%s'", opts.Format)
+	}
+	if opts.Format != "" && source.vmtype != "qemu" {
+		return errors.New("Only VM clones can set the disk format")
+	}
+	if opts.Bwlimit < 0 {
+		return errors.New("Clone bandwidth limit can't be negative")
+	}
+
+	if opts.Full {
+		return
+	}
+
+	if opts.Storage != "" || opts.Format != "" {
+		return errors.New("Target storage and format are only supported by full clones")
+	}
+
+	if fmt.Sprint(vmConfig["template"]) != "1" {
+		return errors.New("Linked clones need a template source, set Full to clone regular guests")
+	}
+
+	for disk, volume := range diskVolumes(vmConfig) {
+		storageName := strings.SplitN(volume, ":", 2)[0]
+
+		var storageInfo map[string]interface{}
+		if storageInfo, err = NewStorage(storageName).GetInfo(); err != nil {
+			return
+		}
+		storageType, _ := storageInfo["type"].(string)
+		if !inArray(linkedCloneStorageTypes, storageType) {
+			return fmt.Errorf("Storage '%s' of %s doesn't support linked clones (type %s)", storageName, disk, storageType)
+		}
+		// the format of file based volumes is their extension
+		if inArray(linkedCloneFileStorageTypes, storageType) && !strings.HasSuffix(volume, ".qcow2") {
+			return fmt.Errorf("Disk %s on storage '%s' (type %s) needs the qcow2 format for linked clones, it's %s", disk, storageName, storageType, volume)
+		}
+	}
+
+	return
+}
+
+func (opts CloneOptions) Params(vmtype string) map[string]interface{} {
+	params := map[string]interface{}{}
+
+	if opts.Full {
+		params["full"] = true
+	}
+	if opts.Name != "" {
+		if vmtype == "qemu" {
+			params["name"] = opts.Name
+		} else {
+			params["hostname"] = opts.Name
+		}
+	}
+
+	optional := map[string]string{
+		"target":      opts.Target,
+		"storage":     opts.Storage,
+		"format":      opts.Format,
+		"pool":        opts.Pool,
+		"description": opts.Description,
+		"snapname":    opts.Snapname,
+	}
+	for k, v := range optional {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	if opts.Bwlimit > 0 {
+		params["bwlimit"] = opts.Bwlimit
+	}
+
+	return params
+}
+
+// needsMigration - whether the clone is for another node and some of the
+// source disks are on non-shared storage, which PVE can't clone across nodes
+// vmConfig is the source config read by sourceConfig
+func (opts CloneOptions) needsMigration(source *Vm, vmConfig map[string]interface{}) (migrate bool, err error) {
+	if opts.Target == "" || opts.Target == source.node.name {
+		return
+	}
+
+	for _, storageName := range diskStorages(vmConfig) {
+		var storageInfo map[string]interface{}
+		if storageInfo, err = NewStorage(storageName).GetInfo(); err != nil {
+			return
+		}
+		if fmt.Sprint(storageInfo["shared"]) != "1" {
+			return true, nil
+		}
+	}
+
+	return
+}
+
+// cloneAndMigrate - clone on the source node, then migrate the (stopped)
+// clone to the target node, moving its disks to opts.Storage if set
+// Only full clones can be moved this way, as linked clones depend on the
+// template disks. The exit status is "OK" when both tasks succeed, otherwise
+// it's the status of the failed task and the error reports both results
+func (vm *Vm) cloneAndMigrate(newid int, opts CloneOptions) (exitStatus string, err error) {
+	if !opts.Full {
+		return "", fmt.Errorf("Linked clones on non-shared storage can't be created on another node (%s), set Full", opts.Target)
+	}
+
+	if newid <= 0 {
+		if newid, err = GetNextVmId(0); err != nil {
+			return
+		}
+	}
+
+	target := opts.Target
+	sourceOpts := opts
+	sourceOpts.Target = ""
+	sourceOpts.Storage = ""
+
+	var cloneStatus string
+	if cloneStatus, err = waitTask(vm.cloneAsync(newid, sourceOpts)); err != nil {
+		return cloneStatus, fmt.Errorf("Clone on node '%s' failed (status: %s): %v", vm.node.name, cloneStatus, err)
+	}
+	if cloneStatus != exitStatusSuccess {
+		return cloneStatus, fmt.Errorf("Clone on node '%s' failed, migration to node '%s' not started (status: %s)", vm.node.name, target, cloneStatus)
+	}
+
+	clone := NewVm(newid)
+	clone.SetNode(vm.node)
+	clone.SetType(vm.vmtype)
+
+	migrateOpts := MigrateOptions{Target: target, Bwlimit: opts.Bwlimit}
+	if opts.Storage != "" {
+		migrateOpts.TargetStorage = map[string]string{"": opts.Storage}
+	}
+
+	var migrateStatus string
+	if migrateStatus, err = clone.Migrate(migrateOpts); err != nil {
+		return migrateStatus, fmt.Errorf("Clone %d on node '%s' succeeded (status: %s), migration to node '%s' failed (status: %s): %v",
+			newid, vm.node.name, cloneStatus, target, migrateStatus, err)
+	}
+	if migrateStatus != exitStatusSuccess {
+		return migrateStatus, fmt.Errorf("Clone %d on node '%s' succeeded (status: %s), migration to node '%s' failed (status: %s)",
+			newid, vm.node.name, cloneStatus, target, migrateStatus)
+	}
+
+	return migrateStatus, nil
+}
+
+// VmConfigUpdater - a guest configuration that can be applied to an existing
+// guest, as ConfigQemu and ConfigLxc
+type VmConfigUpdater interface {
+	UpdateConfig(vm *Vm) error
+}
+
+// CloneAndConfigure - clone the guest and, once the clone is done, apply
+// config to it. With newid <= 0 the next free id is used
+func (vm *Vm) CloneAndConfigure(newid int, opts CloneOptions, config VmConfigUpdater) (clone *Vm, exitStatus string, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	if newid <= 0 {
+		if newid, err = GetNextVmId(0); err != nil {
+			return
+		}
+	}
+
+	if exitStatus, err = vm.Clone(newid, opts); err != nil {
+		return
+	}
+	if exitStatus != exitStatusSuccess {
+		return nil, exitStatus, fmt.Errorf("Clone %d of guest %d failed (status: %s)", newid, vm.id, exitStatus)
+	}
+
+	clone = NewVm(newid)
+	clone.SetType(vm.vmtype)
+	if opts.Target != "" {
+		clone.SetNode(NewNode(opts.Target))
+	} else {
+		clone.SetNode(vm.node)
+	}
+
+	if config != nil {
+		if err = config.UpdateConfig(clone); err != nil {
+			err = fmt.Errorf("Error configuring clone %d: %v", newid, err)
+		}
+	}
+
+	return
+}
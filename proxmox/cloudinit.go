@@ -0,0 +1,284 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// IPConfig - the cloud-init address of a NIC, PVE's ipconfigN option
+// IP is an IPv4 address in CIDR notation or "dhcp", IP6 an IPv6 address in
+// CIDR notation, "dhcp" or "auto" (SLAAC). A gateway needs its address set.
+// Empty values are not set. Options has the options without a field, which
+// are kept as they are
+type IPConfig struct {
+	IP      string            `json:"ip,omitempty"`
+	Gw      string            `json:"gw,omitempty"`
+	IP6     string            `json:"ip6,omitempty"`
+	Gw6     string            `json:"gw6,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// ParseIPConfig - parse the PVE ipconfigN syntax, not validated as it's also
+// used for the API values
+func ParseIPConfig(ipconfig string) (ic IPConfig, err error) {
+	if ipconfig == "" {
+		return
+	}
+
+	for _, item := range strings.Split(ipconfig, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return ic, fmt.Errorf("Invalid ipconfig option '%s'", item)
+		}
+
+		switch kv[0] {
+		case "ip":
+			ic.IP = kv[1]
+		case "gw":
+			ic.Gw = kv[1]
+		case "ip6":
+			ic.IP6 = kv[1]
+		case "gw6":
+			ic.Gw6 = kv[1]
+		default:
+			if ic.Options == nil {
+				ic.Options = map[string]string{}
+			}
+			ic.Options[kv[0]] = kv[1]
+		}
+	}
+
+	return
+}
+
+func (ic IPConfig) IsSet() bool {
+	return ic.IP != "" || ic.Gw != "" || ic.IP6 != "" || ic.Gw6 != "" || len(ic.Options) > 0
+}
+
+func (ic IPConfig) Validate() error {
+	if err := validateIPConfigAddress("ip", ic.IP, ic.Gw, false); err != nil {
+		return err
+	}
+	return validateIPConfigAddress("ip6", ic.IP6, ic.Gw6, true)
+}
+
+func validateIPConfigAddress(name, address, gateway string, isIPv6 bool) error {
+	gwName := strings.Replace(name, "ip", "gw", 1)
+
+	if address == "" && gateway != "" {
+		return fmt.Errorf("Can't set %s without %s", gwName, name)
+	}
+
+	if address != "" && address != "dhcp" && !(isIPv6 && address == "auto") {
+		ip, _, err := net.ParseCIDR(address)
+		if err != nil || (ip.To4() == nil) != isIPv6 {
+			return fmt.Errorf("Invalid %s '%s'", name, address)
+		}
+	}
+
+	if gateway != "" {
+		if gw := net.ParseIP(gateway); gw == nil || (gw.To4() == nil) != isIPv6 {
+			return fmt.Errorf("Invalid %s '%s'", gwName, gateway)
+		}
+	}
+
+	return nil
+}
+
+// String - the PVE ipconfig option syntax
+func (ic IPConfig) String() string {
+	items := []string{}
+	if ic.IP != "" {
+		items = append(items, "ip="+ic.IP)
+	}
+	if ic.Gw != "" {
+		items = append(items, "gw="+ic.Gw)
+	}
+	if ic.IP6 != "" {
+		items = append(items, "ip6="+ic.IP6)
+	}
+	if ic.Gw6 != "" {
+		items = append(items, "gw6="+ic.Gw6)
+	}
+	items = append(items, optionItems(ic.Options)...)
+
+	return strings.Join(items, ",")
+}
+
+// the JSON form is the PVE syntax, an object with the fields is accepted too
+func (ic IPConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ic.String())
+}
+
+func (ic *IPConfig) UnmarshalJSON(b []byte) (err error) {
+	var ipconfig string
+	if err = json.Unmarshal(b, &ipconfig); err == nil {
+		if *ic, err = ParseIPConfig(ipconfig); err == nil {
+			err = ic.Validate()
+		}
+		return
+	}
+
+	type fields IPConfig
+	if err = json.Unmarshal(b, (*fields)(ic)); err != nil {
+		return
+	}
+	return ic.Validate()
+}
+
+// IPConfigs - the cloud-init addresses by NIC index, ipconfigN goes with netN
+// An entry that is not set ("" or {}) removes the address of the NIC
+type IPConfigs map[int]IPConfig
+
+// Indexes - the NIC indexes, sorted
+func (ics IPConfigs) Indexes() (indexes []int) {
+	for index := range ics {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return
+}
+
+// CheckNics - every ipconfigN that is set needs a netN, given as the NIC
+// indexes
+func (ics IPConfigs) CheckNics(nics map[int]bool) error {
+	for _, index := range ics.Indexes() {
+		if ics[index].IsSet() && !nics[index] {
+			return fmt.Errorf("ipconfig%d is set but there is no net%d", index, index)
+		}
+	}
+	return nil
+}
+
+// Params - add an ipconfigN parameter for each address that is set
+func (ics IPConfigs) Params(params map[string]interface{}) {
+	for index, ic := range ics {
+		if ic.IsSet() {
+			params[fmt.Sprintf("ipconfig%d", index)] = ic.String()
+		}
+	}
+}
+
+// Deleted - the ipconfigN options of the entries that are not set, to
+// remove them from the VM
+func (ics IPConfigs) Deleted() (keys []string) {
+	for _, index := range ics.Indexes() {
+		if !ics[index].IsSet() {
+			keys = append(keys, fmt.Sprintf("ipconfig%d", index))
+		}
+	}
+
+	return
+}
+
+// CICustom - custom cloud-init files replacing the generated ones, PVE's
+// cicustom option. Each one is a volume ID of a snippet, like
+// "local:snippets/user-data.yaml", empty values are generated by PVE
+type CICustom struct {
+	User    string `json:"user,omitempty"`
+	Network string `json:"network,omitempty"`
+	Meta    string `json:"meta,omitempty"`
+	Vendor  string `json:"vendor,omitempty"`
+}
+
+func ParseCICustom(cicustom string) (cc CICustom, err error) {
+	if cicustom == "" {
+		return
+	}
+
+	for _, item := range strings.Split(cicustom, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return cc, fmt.Errorf("Invalid cicustom option '%s'", item)
+		}
+
+		switch kv[0] {
+		case "user":
+			cc.User = kv[1]
+		case "network":
+			cc.Network = kv[1]
+		case "meta":
+			cc.Meta = kv[1]
+		case "vendor":
+			cc.Vendor = kv[1]
+		default:
+			return cc, fmt.Errorf("Invalid cicustom option '%s'", kv[0])
+		}
+	}
+
+	return
+}
+
+func (cc CICustom) IsSet() bool {
+	return cc.User != "" || cc.Network != "" || cc.Meta != "" || cc.Vendor != ""
+}
+
+// String - the PVE cicustom option syntax
+func (cc CICustom) String() string {
+	items := []string{}
+	if cc.User != "" {
+		items = append(items, "user="+cc.User)
+	}
+	if cc.Network != "" {
+		items = append(items, "network="+cc.Network)
+	}
+	if cc.Meta != "" {
+		items = append(items, "meta="+cc.Meta)
+	}
+	if cc.Vendor != "" {
+		items = append(items, "vendor="+cc.Vendor)
+	}
+
+	return strings.Join(items, ",")
+}
+
+// the JSON form is the PVE syntax, an object with the fields is accepted too
+func (cc CICustom) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cc.String())
+}
+
+func (cc *CICustom) UnmarshalJSON(b []byte) (err error) {
+	var cicustom string
+	if err = json.Unmarshal(b, &cicustom); err == nil {
+		*cc, err = ParseCICustom(cicustom)
+		return
+	}
+
+	type fields CICustom
+	return json.Unmarshal(b, (*fields)(cc))
+}
+
+// cloud-init image formats for the citype option, PVE picks one by ostype
+// when it's not set
+var ciTypes = []string{"nocloud", "configdrive2", "opennebula"}
+
+func validateCIType(citype string) error {
+	if citype != "" && !inArray(ciTypes, citype) {
+		return fmt.Errorf("Invalid citype '%s', must be one of %s", citype, strings.Join(ciTypes, ", "))
+	}
+	return nil
+}
+
+// RegenerateCloudInit - rebuild the cloud-init drive of the VM from its
+// current config, which PVE otherwise only does when the VM starts
+func (vm *Vm) RegenerateCloudInit() (err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+	if vm.vmtype != "qemu" {
+		return fmt.Errorf("Cloud-init is only supported on qemu VMs, guest %d is %s", vm.id, vm.vmtype)
+	}
+
+	var resp *http.Response
+	ciUrl := fmt.Sprintf("/nodes/%s/qemu/%d/cloudinit", vm.node.name, vm.id)
+	if resp, err = GetClient().session.Put(ciUrl, nil, nil, nil); err != nil {
+		return fmt.Errorf("Error regenerating cloud-init drive of VM %d: %v", vm.id, err)
+	}
+	resp.Body.Close()
+
+	return
+}
@@ -15,30 +15,30 @@ import (
 
 // ConfigLxc - Proxmox API LXC options
 type ConfigLxc struct {
-	Arch         string    `json:"arch"`
-	Cmode        string    `json:"cmode"`
-	Console      bool      `json:"console"`
-	Cores        int       `json:"cores"`
-	Cpuunits     int       `json:"cpuunits"`
-	Description  string    `json:"description"`
-	Digest       string    `json:"digest"`
-	Hostname     string    `json:"hostname"`
-	Memory       int       `json:"memory"`
-	Mp           VmDevices `json:"mp"`
-	Nameserver   string    `json:"nameserver"`
-	Net          VmDevices `json:"net"`
-	Onboot       bool      `json:"onboot"`
-	Ostype       string    `json:"ostype"`
-	Ostemplate   string    `json:"ostemplate"`
-	Password     string    `json:"password"`
-	Protection   bool      `json:"protection"`
-	Rootfs       VmDevice  `json:"rootfs"`
-	Searchdomain string    `json:"searchdomain"`
-	Startup      string    `json:"startup"`
-	Sshkeys      string    `json:"ssh-public-keys"`
-	Swap         int       `json:"swap"`
-	Tty          int       `json:"tty"`
-	Unprivileged bool      `json:"unprivileged"`
+	Arch         string       `json:"arch"`
+	Cmode        string       `json:"cmode"`
+	Console      bool         `json:"console"`
+	Cores        int          `json:"cores"`
+	Cpuunits     int          `json:"cpuunits"`
+	Description  string       `json:"description"`
+	Digest       string       `json:"digest"`
+	Hostname     string       `json:"hostname"`
+	Memory       int          `json:"memory"`
+	Mp           VmDevices    `json:"mp"`
+	Nameserver   string       `json:"nameserver"`
+	Net          VmDevices    `json:"net"`
+	Onboot       bool         `json:"onboot"`
+	Ostype       string       `json:"ostype"`
+	Ostemplate   string       `json:"ostemplate"`
+	Password     string       `json:"password"`
+	Protection   bool         `json:"protection"`
+	Rootfs       VmDevice     `json:"rootfs"`
+	Searchdomain string       `json:"searchdomain"`
+	Startup      StartupOrder `json:"startup"`
+	Sshkeys      string       `json:"ssh-public-keys"`
+	Swap         int          `json:"swap"`
+	Tty          int          `json:"tty"`
+	Unprivileged bool         `json:"unprivileged"`
 }
 
 // CreateVm - Tell Proxmox API to make the VM
@@ -68,8 +68,8 @@ func (config ConfigLxc) CreateVm(vm *Vm) (err error) {
 		"unprivileged":    config.Unprivileged,
 	}
 
-	if config.Startup != "" {
-		params["startup"] = config.Startup
+	if config.Startup.IsSet() {
+		params["startup"] = config.Startup.String()
 	}
 
 	// Create mountpoints config.
@@ -109,6 +109,9 @@ func (config ConfigLxc) UpdateConfig(vm *Vm) (err error) {
 	if config.Searchdomain != "" {
 		params["searchdomain"] = config.Searchdomain
 	}
+	if config.Startup.IsSet() {
+		params["startup"] = config.Startup.String()
+	}
 
 	// Decoder.Decode uses the struct, which "always" will have its members
 	// set by default to a zero value. The zero value can't be tell apart from
@@ -163,7 +166,7 @@ func NewConfigLxc() *ConfigLxc {
 		Rootfs:       VmDevice{},
 		Searchdomain: "",
 		Sshkeys:      "",
-		Startup:      "",
+		Startup:      StartupOrder{},
 		Swap:         512,
 		Tty:          2,
 		Unprivileged: false,
@@ -251,7 +254,7 @@ func NewConfigLxcFromApi(vm *Vm) (config *ConfigLxc, err error) {
 		config.Searchdomain = vmConfig["searchdomain"].(string)
 	}
 	if _, isSet := vmConfig["startup"]; isSet {
-		config.Startup = vmConfig["startup"].(string)
+		config.Startup = readStartupOrder(vmConfig["startup"].(string))
 	}
 	if _, isSet := vmConfig["swap"]; isSet {
 		config.Swap = int(vmConfig["swap"].(float64))
@@ -17,37 +17,85 @@ import (
 
 // ConfigQemu - Proxmox API QEMU options
 type ConfigQemu struct {
-	Name        string    `json:"name"`
-	Description string    `json:"desc"`
-	Onboot      bool      `json:"onboot"`
-	Agent       string    `json:"agent"`
-	Memory      int       `json:"memory"`
-	Ostype      string    `json:"ostype"`
-	Cores       int       `json:"cores"`
-	Sockets     int       `json:"sockets"`
-	Iso         string    `json:"iso"`
-	Disk        VmDevices `json:"disk"`
-	Net         VmDevices `json:"net"`
+	Name        string       `json:"name"`
+	Description string       `json:"desc"`
+	Onboot      bool         `json:"onboot"`
+	Startup     StartupOrder `json:"startup"`
+	Agent       string       `json:"agent"`
+	Memory      int          `json:"memory"`
+	Ostype      string       `json:"ostype"`
+	Cores       int          `json:"cores"`
+	Sockets     int          `json:"sockets"`
+	Iso         string       `json:"iso"`
+	Disk        VmDevices    `json:"disk"`
+	Net         VmDevices    `json:"net"`
+
+	// hardware options, see config_qemu_hardware.go. The numbers are only
+	// sent when above 0, they go back to the PVE default through Delete
+	Cpu        string                 `json:"cpu"`
+	CpuFlags   string                 `json:"cpuflags"`
+	Numa       *bool                  `json:"numa,omitempty"`
+	Vcpus      int                    `json:"vcpus"`
+	Cpulimit   float64                `json:"cpulimit"`
+	Cpuunits   int                    `json:"cpuunits"`
+	Balloon    *int                   `json:"balloon,omitempty"`
+	Shares     int                    `json:"shares"`
+	Bios       string                 `json:"bios"`
+	Efidisk    EFIDisk                `json:"efidisk"`
+	Machine    string                 `json:"machine"`
+	Scsihw     string                 `json:"scsihw"`
+	Vga        string                 `json:"vga"`
+	Boot       string                 `json:"boot"`
+	Hotplug    string                 `json:"hotplug"`
+	Tablet     *bool                  `json:"tablet,omitempty"`
+	Kvm        *bool                  `json:"kvm,omitempty"`
+	Hugepages  string                 `json:"hugepages"`
+	Tpmstate   TPMState               `json:"tpmstate"`
+	Serial     map[int]string         `json:"serial"`
+	Usb        map[int]USBPassthrough `json:"usb"`
+	Hostpci    map[int]PCIPassthrough `json:"hostpci"`
+	Smbios1    string                 `json:"smbios1"`
+	Vmgenid    string                 `json:"vmgenid"`
+	Protection *bool                  `json:"protection,omitempty"`
 
 	// cloud-init options
 	CIuser     string `json:"ciuser"`
 	CIpassword string `json:"cipassword"`
 
-	Searchdomain string `json:"searchdomain"`
-	Nameserver   string `json:"nameserver"`
-	Sshkeys      string `json:"sshkeys"`
+	Searchdomain string    `json:"searchdomain"`
+	Nameserver   string    `json:"nameserver"`
+	Sshkeys      string    `json:"sshkeys"`
+	Ipconfig     IPConfigs `json:"ipconfig"`
+	Cicustom     CICustom  `json:"cicustom"`
+	Citype       string    `json:"citype"`
 
-	// arrays are hard, support 2 interfaces for now
-	Ipconfig0 string `json:"ipconfig0"`
-	Ipconfig1 string `json:"ipconfig1"`
+	// cloud-init drive and image to boot from, for new VMs
+	CIStorage string `json:"cistorage"`
+	CIDrive   string `json:"cidrive"`
+	Image     string `json:"image"`
 
 	Delete string `json:"delete"`
 }
 
 // CreateVm - Tell Proxmox API to make the VM
+// The efidisk and tpmstate without a file are allocated in their storage,
+// for UEFI Secure Boot and TPM 2.0 guests like Windows 11.
+// With cloud-init options the VM gets a cloud-init drive in CIStorage, and
+// Image (a cloud image volume or absolute path on the node) is imported as
+// the first disk to boot from, so no template is needed. Other disks can
+// be imported with their import_from key
 func (config ConfigQemu) CreateVm(vm *Vm) (err error) {
-	if config.HasCloudInit() {
-		return errors.New("Cloud-init parameters only supported on clones or updates")
+	if err = config.checkImports(); err != nil {
+		return
+	}
+	if err = config.checkCloudInit(); err != nil {
+		return
+	}
+	if err = config.checkStateDisks(nil); err != nil {
+		return
+	}
+	if err = config.checkPassthrough(); err != nil {
+		return
 	}
 	vm.SetType("qemu")
 
@@ -56,23 +104,54 @@ func (config ConfigQemu) CreateVm(vm *Vm) (err error) {
 		"name":        config.Name,
 		"onboot":      config.Onboot,
 		"agent":       config.Agent,
-		"ide2":        config.Iso + ",media=cdrom",
 		"ostype":      config.Ostype,
 		"sockets":     config.Sockets,
 		"cores":       config.Cores,
-		"cpu":         "host",
+		"cpu":         config.cpuParam(defaultCpu),
 		"memory":      config.Memory,
 		"description": config.Description,
 	}
 
+	if config.Startup.IsSet() {
+		params["startup"] = config.Startup.String()
+	}
+
+	config.hardwareParams(params)
+
+	// The image is imported as disk 0.
+	if config.Image != "" {
+		config.Disk = config.Disk.withImport(0, config.Image)
+	}
+
 	// Create disks config.
 	config.CreateDisksParams(vm.id, params, false)
 
 	// Create networks config.
 	config.CreateNetParams(vm.id, params)
 
-	if exitStatus, err := vm.Create(params); err != nil {
-		err = fmt.Errorf("Error creating VM: %v, error status: %s (params: %v)", err, exitStatus, params)
+	if config.Iso != "" || config.Image == "" {
+		params["ide2"] = config.Iso + ",media=cdrom"
+	}
+
+	if config.Image != "" && config.Boot == "" {
+		params["boot"] = fmt.Sprintf("order=%v0", config.Disk[0]["type"])
+	}
+
+	if config.CIStorage != "" {
+		params[config.ciDrive()] = config.CIStorage + ":cloudinit"
+		config.cloudInitParams(params)
+	}
+
+	var exitStatus string
+	if exitStatus, err = vm.Create(params); err != nil {
+		return fmt.Errorf("Error creating VM: %v, error status: %s (params: %v)", err, exitStatus, params)
+	}
+	if exitStatus != exitStatusSuccess {
+		return fmt.Errorf("Error creating VM, error status: %s (params: %v)", exitStatus, params)
+	}
+
+	if config.CIStorage != "" {
+		err = vm.RegenerateCloudInit()
 	}
 
 	return
@@ -85,55 +164,185 @@ func (config ConfigQemu) HasCloudInit() bool {
 		config.Searchdomain != "" ||
 		config.Nameserver != "" ||
 		config.Sshkeys != "" ||
-		config.Ipconfig0 != "" ||
-		config.Ipconfig1 != ""
+		len(config.Ipconfig) > 0 ||
+		config.Cicustom.IsSet() ||
+		config.Citype != ""
 }
 
-func (config ConfigQemu) UpdateConfig(vm *Vm) (err error) {
-	configParams := map[string]interface{}{
-		"name":        config.Name,
-		"description": config.Description,
-		"onboot":      config.Onboot,
-		"agent":       config.Agent,
-		"sockets":     config.Sockets,
-		"cores":       config.Cores,
-		"memory":      config.Memory,
+// the cloud-init drive slot when CIDrive is not set, ide2 is the CD-ROM
+const defaultCIDrive = "ide0"
+
+func (config ConfigQemu) ciDrive() string {
+	if config.CIDrive != "" {
+		return config.CIDrive
 	}
+	return defaultCIDrive
+}
 
-	// Create disks config.
-	config.CreateDisksParams(vm.id, configParams, true)
+// checkImports - the imported images need a disk type and storage to
+// import to
+func (config ConfigQemu) checkImports() error {
+	if _, isSet := config.Disk[0]; config.Image != "" && !isSet {
+		return errors.New("Importing an image needs disk 0 to set its type and storage")
+	}
+	for diskID, diskConfMap := range config.Disk {
+		importFrom, _ := diskConfMap["import_from"].(string)
+		if importFrom == "" && (diskID != 0 || config.Image == "") {
+			continue
+		}
+		diskType, _ := diskConfMap["type"].(string)
+		storage, _ := diskConfMap["storage"].(string)
+		if diskType == "" || storage == "" {
+			return fmt.Errorf("Disk %d needs a type and a storage to import the image", diskID)
+		}
+	}
 
-	// Create networks config.
-	config.CreateNetParams(vm.id, configParams)
+	return nil
+}
 
-	// cloud-init options
+// checkCloudInit - the cloud-init options of a new VM need a drive to
+// write them to
+func (config ConfigQemu) checkCloudInit() (err error) {
+	if config.HasCloudInit() && config.CIStorage == "" {
+		return errors.New("Cloud-init parameters need a cistorage for the cloud-init drive")
+	}
+	if config.CIStorage == "" {
+		return
+	}
+
+	if !rxCIDrive.MatchString(config.ciDrive()) {
+		return fmt.Errorf("Invalid cloud-init drive '%s'", config.ciDrive())
+	}
+	if config.ciDrive() == "ide2" {
+		return errors.New("Cloud-init drive can't be ide2, it's used for the CD-ROM")
+	}
+	if err = validateCIType(config.Citype); err != nil {
+		return
+	}
+
+	return config.checkIpconfig(nil)
+}
+
+// cloudInitParams - add the cloud-init options that are set to params
+func (config ConfigQemu) cloudInitParams(params map[string]interface{}) {
 	if config.CIuser != "" {
-		configParams["ciuser"] = config.CIuser
+		params["ciuser"] = config.CIuser
 	}
 	if config.CIpassword != "" {
-		configParams["cipassword"] = config.CIpassword
+		params["cipassword"] = config.CIpassword
 	}
 	if config.Searchdomain != "" {
-		configParams["searchdomain"] = config.Searchdomain
+		params["searchdomain"] = config.Searchdomain
 	}
 	if config.Nameserver != "" {
-		configParams["nameserver"] = config.Nameserver
+		params["nameserver"] = config.Nameserver
 	}
 	if config.Sshkeys != "" {
 		sshkeyEnc := url.PathEscape(config.Sshkeys + "\n")
 		sshkeyEnc = strings.Replace(sshkeyEnc, "+", "%2B", -1)
 		sshkeyEnc = strings.Replace(sshkeyEnc, "@", "%40", -1)
 		sshkeyEnc = strings.Replace(sshkeyEnc, "=", "%3D", -1)
-		configParams["sshkeys"] = sshkeyEnc
+		params["sshkeys"] = sshkeyEnc
+	}
+	config.Ipconfig.Params(params)
+	if config.Cicustom.IsSet() {
+		params["cicustom"] = config.Cicustom.String()
+	}
+	if config.Citype != "" {
+		params["citype"] = config.Citype
 	}
-	if config.Ipconfig0 != "" {
-		configParams["ipconfig0"] = config.Ipconfig0
+}
+
+// checkIpconfig - the NICs of the config and the ones already in vmConfig
+// (when updating) must cover all the ipconfig entries
+func (config ConfigQemu) checkIpconfig(vmConfig map[string]interface{}) error {
+	nics := map[int]bool{}
+	for nicID := range config.Net {
+		nics[nicID] = true
+	}
+	for k := range vmConfig {
+		if rxNicName.MatchString(k) {
+			nicID, _ := strconv.Atoi(rxDeviceID.FindString(k))
+			nics[nicID] = true
+		}
+	}
+
+	return config.Ipconfig.CheckNics(nics)
+}
+
+func (config ConfigQemu) UpdateConfig(vm *Vm) (err error) {
+	if err = config.checkImports(); err != nil {
+		return
+	}
+	if err = validateCIType(config.Citype); err != nil {
+		return
+	}
+	if err = config.checkPassthrough(); err != nil {
+		return
+	}
+
+	importedDisks := config.importedDisks()
+
+	var vmConfig map[string]interface{}
+	if len(config.Ipconfig) > 0 || config.Efidisk.IsSet() || config.Tpmstate.IsSet() || len(importedDisks) > 0 {
+		if vmConfig, err = vm.GetConfig(); err != nil {
+			return
+		}
 	}
-	if config.Ipconfig1 != "" {
-		configParams["ipconfig1"] = config.Ipconfig1
+	if err = config.checkStateDisks(vmConfig); err != nil {
+		return
 	}
+	if len(config.Ipconfig) > 0 {
+		if err = config.checkIpconfig(vmConfig); err != nil {
+			return
+		}
+	}
+
+	configParams := map[string]interface{}{
+		"name":        config.Name,
+		"description": config.Description,
+		"onboot":      config.Onboot,
+		"agent":       config.Agent,
+		"sockets":     config.Sockets,
+		"cores":       config.Cores,
+		"memory":      config.Memory,
+	}
+
+	if config.Startup.IsSet() {
+		configParams["startup"] = config.Startup.String()
+	}
+
+	if config.Cpu != "" || config.CpuFlags != "" {
+		configParams["cpu"] = config.cpuParam(defaultCpu)
+	}
+	config.hardwareParams(configParams)
+
+	// Create disks config.
+	config.CreateDisksParams(vm.id, configParams, true)
+
+	// Create networks config.
+	config.CreateNetParams(vm.id, configParams)
+
+	// cloud-init options
+	config.cloudInitParams(configParams)
+	deletes := config.Ipconfig.Deleted()
 	if config.Delete != "" {
-		configParams["delete"] = config.Delete
+		deletes = append([]string{config.Delete}, deletes...)
+	}
+	if len(deletes) > 0 {
+		configParams["delete"] = strings.Join(deletes, ",")
+	}
+
+	// keep the efidisk and tpmstate the VM has instead of allocating new ones
+	for _, key := range allocatesStateDisks(configParams, vmConfig) {
+		delete(configParams, key)
+	}
+
+	// and the disks imported before instead of importing the image again
+	for _, key := range importedDisks {
+		if _, hasDisk := vmConfig[key]; hasDisk {
+			delete(configParams, key)
+		}
 	}
 
 	_, err = vm.SetConfig(configParams)
@@ -156,7 +365,10 @@ var (
 	rxDeviceID = regexp.MustCompile(`\d+`)
 	rxDiskName = regexp.MustCompile(`(virtio|scsi)\d+`)
 	rxDiskType = regexp.MustCompile(`\D+`)
-	rxNicName  = regexp.MustCompile(`net\d+`)
+	rxNicName  = regexp.MustCompile(`^net\d+$`)
+
+	rxIpconfigName = regexp.MustCompile(`^ipconfig\d+$`)
+	rxCIDrive      = regexp.MustCompile(`^(ide|sata|scsi)\d+$`)
 )
 
 func NewConfigQemuFromApi(vm *Vm) (config *ConfigQemu, err error) {
@@ -238,6 +450,7 @@ func NewConfigQemuFromApi(vm *Vm) (config *ConfigQemu, err error) {
 		Sockets:     int(sockets),
 		Disk:        VmDevices{},
 		Net:         VmDevices{},
+		Ipconfig:    IPConfigs{},
 	}
 
 	if vmConfig["ide2"] != nil {
@@ -245,6 +458,14 @@ func NewConfigQemuFromApi(vm *Vm) (config *ConfigQemu, err error) {
 		config.Iso = isoMatch[1]
 	}
 
+	if _, isSet := vmConfig["startup"]; isSet {
+		config.Startup = readStartupOrder(vmConfig["startup"].(string))
+	}
+
+	if err = config.readHardwareConfig(vmConfig); err != nil {
+		return nil, err
+	}
+
 	if _, isSet := vmConfig["ciuser"]; isSet {
 		config.CIuser = vmConfig["ciuser"].(string)
 	}
@@ -257,11 +478,22 @@ func NewConfigQemuFromApi(vm *Vm) (config *ConfigQemu, err error) {
 	if _, isSet := vmConfig["sshkeys"]; isSet {
 		config.Sshkeys, _ = url.PathUnescape(vmConfig["sshkeys"].(string))
 	}
-	if _, isSet := vmConfig["ipconfig0"]; isSet {
-		config.Ipconfig0 = vmConfig["ipconfig0"].(string)
+	if _, isSet := vmConfig["cicustom"]; isSet {
+		if config.Cicustom, err = ParseCICustom(vmConfig["cicustom"].(string)); err != nil {
+			return nil, err
+		}
+	}
+	if _, isSet := vmConfig["citype"]; isSet {
+		config.Citype = vmConfig["citype"].(string)
 	}
-	if _, isSet := vmConfig["ipconfig1"]; isSet {
-		config.Ipconfig1 = vmConfig["ipconfig1"].(string)
+	for k, v := range vmConfig {
+		if !rxIpconfigName.MatchString(k) {
+			continue
+		}
+		nicID, _ := strconv.Atoi(rxDeviceID.FindString(k))
+		if config.Ipconfig[nicID], err = ParseIPConfig(v.(string)); err != nil {
+			return nil, err
+		}
 	}
 
 	// Add disks.
@@ -396,6 +628,14 @@ func (c ConfigQemu) CreateDisksParams(
 		if diskID == 0 && cloned {
 			continue
 		}
+
+		// Disks imported from an image are allocated by PVE with the image
+		// size, they are not created beforehand as the rest.
+		if importFrom, _ := diskConfMap["import_from"].(string); importFrom != "" {
+			params[fmt.Sprintf("%v%d", diskConfMap["type"], diskID)] = importDiskParam(diskConfMap)
+			continue
+		}
+
 		diskConfParam := VmDeviceParam{
 			"media=disk",
 		}
@@ -440,3 +680,49 @@ func (c ConfigQemu) CreateDisksParams(
 
 	return
 }
+
+// importDiskParam - the parameter of a disk imported from the volume or
+// absolute path in its import_from key (PVE 7.2+), where size 0 takes the
+// size of the image
+func importDiskParam(diskConfMap VmDevice) string {
+	diskConfParam := VmDeviceParam{
+		fmt.Sprintf("%v:0", diskConfMap["storage"]),
+		fmt.Sprintf("import-from=%v", diskConfMap["import_from"]),
+	}
+	if cache, _ := diskConfMap["cache"].(string); cache != "" && cache != "none" {
+		diskConfParam = append(diskConfParam, "cache="+cache)
+	}
+
+	// Keys that are not used as real/direct conf.
+	ignoredKeys := []string{"id", "type", "storage", "storage_type", "size", "cache", "file", "media", "import_from"}
+
+	return strings.Join(diskConfParam.createDeviceParam(diskConfMap, ignoredKeys), ",")
+}
+
+// importedDisks - the disk keys (as scsi0) imported with import_from
+func (c ConfigQemu) importedDisks() (keys []string) {
+	for diskID, diskConfMap := range c.Disk {
+		if importFrom, _ := diskConfMap["import_from"].(string); importFrom != "" {
+			keys = append(keys, fmt.Sprintf("%v%d", diskConfMap["type"], diskID))
+		}
+	}
+
+	return
+}
+
+// withImport - a copy of the devices where the device id imports the image
+func (devices VmDevices) withImport(id int, image string) VmDevices {
+	imported := VmDevices{}
+	for deviceID, deviceConfMap := range devices {
+		imported[deviceID] = deviceConfMap
+	}
+
+	deviceConfMap := VmDevice{}
+	for key, value := range devices[id] {
+		deviceConfMap[key] = value
+	}
+	deviceConfMap["import_from"] = image
+	imported[id] = deviceConfMap
+
+	return imported
+}
@@ -0,0 +1,306 @@
+package proxmox
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the cpu type of new VMs when none is given
+const defaultCpu = "host"
+
+// numbered devices, by index
+var rxHardwareDevice = regexp.MustCompile(`^(serial|usb|hostpci)(\d+)$`)
+
+// cpuParam - the cpu option, Cpu is the type optionally followed by more
+// cpu options as in "host,hidden=1", CpuFlags the ';' separated flags as in
+// "+aes;-pcid"
+func (config ConfigQemu) cpuParam(defaultType string) string {
+	cpu := config.Cpu
+	if cpu == "" {
+		cpu = defaultType
+	}
+	if config.CpuFlags != "" {
+		cpu += ",flags=" + config.CpuFlags
+	}
+
+	return cpu
+}
+
+// checkPassthrough - validate the passed through devices, PCIe needs the
+// q35 machine type
+func (config ConfigQemu) checkPassthrough() error {
+	for id, pci := range config.Hostpci {
+		if err := pci.Validate(); err != nil {
+			return fmt.Errorf("hostpci%d: %v", id, err)
+		}
+		if pci.Pcie && config.Machine != "" && !strings.Contains(config.Machine, "q35") {
+			return fmt.Errorf("hostpci%d: pcie needs the q35 machine type, not '%s'", id, config.Machine)
+		}
+	}
+	for id, usb := range config.Usb {
+		if err := usb.Validate(); err != nil {
+			return fmt.Errorf("usb%d: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// checkStateDisks - validate the efidisk and tpmstate, the efidisk is only
+// used by OVMF. When updating, vmConfig is the current config of the VM and
+// its bios is used if config doesn't set one
+func (config ConfigQemu) checkStateDisks(vmConfig map[string]interface{}) error {
+	if err := config.Efidisk.Validate(); err != nil {
+		return err
+	}
+	if err := config.Tpmstate.Validate(); err != nil {
+		return err
+	}
+
+	bios := config.Bios
+	if _, isSet := vmConfig["bios"]; bios == "" && isSet {
+		bios = configString(vmConfig["bios"])
+	}
+	if config.Efidisk.IsSet() && bios != "ovmf" {
+		return fmt.Errorf("The efidisk needs bios ovmf, not '%s'", bios)
+	}
+
+	return nil
+}
+
+// hardwareParams - add the hardware options that are set to params, except
+// the cpu type
+func (config ConfigQemu) hardwareParams(params map[string]interface{}) {
+	if config.Numa != nil {
+		params["numa"] = *config.Numa
+	}
+	if config.Vcpus > 0 {
+		params["vcpus"] = config.Vcpus
+	}
+	if config.Cpulimit > 0 {
+		params["cpulimit"] = strconv.FormatFloat(config.Cpulimit, 'f', -1, 64)
+	}
+	if config.Cpuunits > 0 {
+		params["cpuunits"] = config.Cpuunits
+	}
+	if config.Balloon != nil {
+		params["balloon"] = *config.Balloon
+	}
+	if config.Shares > 0 {
+		params["shares"] = config.Shares
+	}
+	if config.Bios != "" {
+		params["bios"] = config.Bios
+	}
+	if config.Efidisk.IsSet() {
+		params["efidisk0"] = config.Efidisk.String()
+	}
+	if config.Machine != "" {
+		params["machine"] = config.Machine
+	}
+	if config.Scsihw != "" {
+		params["scsihw"] = config.Scsihw
+	}
+	if config.Vga != "" {
+		params["vga"] = config.Vga
+	}
+	if config.Boot != "" {
+		params["boot"] = config.Boot
+	}
+	if config.Hotplug != "" {
+		params["hotplug"] = config.Hotplug
+	}
+	if config.Tablet != nil {
+		params["tablet"] = *config.Tablet
+	}
+	if config.Kvm != nil {
+		params["kvm"] = *config.Kvm
+	}
+	if config.Hugepages != "" {
+		params["hugepages"] = config.Hugepages
+	}
+	if config.Tpmstate.IsSet() {
+		params["tpmstate0"] = config.Tpmstate.String()
+	}
+	for id, serial := range config.Serial {
+		params[fmt.Sprintf("serial%d", id)] = serial
+	}
+	for id, usb := range config.Usb {
+		params[fmt.Sprintf("usb%d", id)] = usb.String()
+	}
+	for id, hostpci := range config.Hostpci {
+		params[fmt.Sprintf("hostpci%d", id)] = hostpci.String()
+	}
+	if config.Smbios1 != "" {
+		params["smbios1"] = config.Smbios1
+	}
+	if config.Vmgenid != "" {
+		params["vmgenid"] = config.Vmgenid
+	}
+	if config.Protection != nil {
+		params["protection"] = *config.Protection
+	}
+}
+
+// readHardwareConfig - set the hardware options from the API config
+func (config *ConfigQemu) readHardwareConfig(vmConfig map[string]interface{}) (err error) {
+	if _, isSet := vmConfig["cpu"]; isSet {
+		cpuOptions := []string{}
+		for _, item := range strings.Split(configString(vmConfig["cpu"]), ",") {
+			switch {
+			case strings.HasPrefix(item, "flags="):
+				config.CpuFlags = strings.TrimPrefix(item, "flags=")
+			case strings.HasPrefix(item, "cputype="):
+				cpuOptions = append([]string{strings.TrimPrefix(item, "cputype=")}, cpuOptions...)
+			default:
+				cpuOptions = append(cpuOptions, item)
+			}
+		}
+		config.Cpu = strings.Join(cpuOptions, ",")
+	}
+
+	if _, isSet := vmConfig["numa"]; isSet {
+		numa := configBool(vmConfig["numa"])
+		config.Numa = &numa
+	}
+	if _, isSet := vmConfig["vcpus"]; isSet {
+		if config.Vcpus, err = configInt(vmConfig["vcpus"]); err != nil {
+			return
+		}
+	}
+	if _, isSet := vmConfig["cpulimit"]; isSet {
+		if config.Cpulimit, err = configFloat(vmConfig["cpulimit"]); err != nil {
+			return
+		}
+	}
+	if _, isSet := vmConfig["cpuunits"]; isSet {
+		if config.Cpuunits, err = configInt(vmConfig["cpuunits"]); err != nil {
+			return
+		}
+	}
+	if _, isSet := vmConfig["balloon"]; isSet {
+		var balloon int
+		if balloon, err = configInt(vmConfig["balloon"]); err != nil {
+			return
+		}
+		config.Balloon = &balloon
+	}
+	if _, isSet := vmConfig["shares"]; isSet {
+		if config.Shares, err = configInt(vmConfig["shares"]); err != nil {
+			return
+		}
+	}
+	if _, isSet := vmConfig["bios"]; isSet {
+		config.Bios = configString(vmConfig["bios"])
+	}
+	if _, isSet := vmConfig["efidisk0"]; isSet {
+		if config.Efidisk, err = ParseEFIDisk(configString(vmConfig["efidisk0"])); err != nil {
+			return
+		}
+	}
+	if _, isSet := vmConfig["machine"]; isSet {
+		config.Machine = configString(vmConfig["machine"])
+	}
+	if _, isSet := vmConfig["scsihw"]; isSet {
+		config.Scsihw = configString(vmConfig["scsihw"])
+	}
+	if _, isSet := vmConfig["vga"]; isSet {
+		config.Vga = configString(vmConfig["vga"])
+	}
+	if _, isSet := vmConfig["boot"]; isSet {
+		config.Boot = configString(vmConfig["boot"])
+	}
+	if _, isSet := vmConfig["hotplug"]; isSet {
+		config.Hotplug = configString(vmConfig["hotplug"])
+	}
+	if _, isSet := vmConfig["tablet"]; isSet {
+		tablet := configBool(vmConfig["tablet"])
+		config.Tablet = &tablet
+	}
+	if _, isSet := vmConfig["kvm"]; isSet {
+		kvm := configBool(vmConfig["kvm"])
+		config.Kvm = &kvm
+	}
+	if _, isSet := vmConfig["hugepages"]; isSet {
+		config.Hugepages = configString(vmConfig["hugepages"])
+	}
+	if _, isSet := vmConfig["tpmstate0"]; isSet {
+		if config.Tpmstate, err = ParseTPMState(configString(vmConfig["tpmstate0"])); err != nil {
+			return
+		}
+	}
+	if _, isSet := vmConfig["smbios1"]; isSet {
+		config.Smbios1 = configString(vmConfig["smbios1"])
+	}
+	if _, isSet := vmConfig["vmgenid"]; isSet {
+		config.Vmgenid = configString(vmConfig["vmgenid"])
+	}
+	if _, isSet := vmConfig["protection"]; isSet {
+		protection := configBool(vmConfig["protection"])
+		config.Protection = &protection
+	}
+
+	for key, value := range vmConfig {
+		match := rxHardwareDevice.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		id, _ := strconv.Atoi(match[2])
+		switch match[1] {
+		case "serial":
+			if config.Serial == nil {
+				config.Serial = map[int]string{}
+			}
+			config.Serial[id] = configString(value)
+		case "usb":
+			if config.Usb == nil {
+				config.Usb = map[int]USBPassthrough{}
+			}
+			if config.Usb[id], err = ParseUSBPassthrough(configString(value)); err != nil {
+				return
+			}
+		case "hostpci":
+			if config.Hostpci == nil {
+				config.Hostpci = map[int]PCIPassthrough{}
+			}
+			if config.Hostpci[id], err = ParsePCIPassthrough(configString(value)); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// PVE returns some numeric options as numbers and others as strings,
+// depending on the version and the option format
+
+func configString(value interface{}) string {
+	if number, isNumber := value.(float64); isNumber {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func configInt(value interface{}) (int, error) {
+	number, err := strconv.Atoi(configString(value))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid integer option value '%v'", value)
+	}
+	return number, nil
+}
+
+func configFloat(value interface{}) (float64, error) {
+	number, err := strconv.ParseFloat(configString(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid number option value '%v'", value)
+	}
+	return number, nil
+}
+
+func configBool(value interface{}) bool {
+	return configString(value) == "1"
+}
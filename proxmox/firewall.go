@@ -0,0 +1,469 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+const clusterFirewallUrl = "/cluster/firewall"
+
+// Firewall - firewall configuration at the cluster, node or guest scope
+type Firewall struct {
+	url string
+}
+
+// factory for the datacenter wide firewall
+func NewClusterFirewall() *Firewall {
+	return &Firewall{url: clusterFirewallUrl}
+}
+
+// factory for the host firewall of a node
+func (node *Node) Firewall() *Firewall {
+	return &Firewall{url: fmt.Sprintf("/nodes/%s/firewall", node.name)}
+}
+
+// factory for the firewall of a VM or CT
+func (vm *Vm) Firewall() (fw *Firewall, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	return &Firewall{url: fmt.Sprintf("/nodes/%s/%s/%d/firewall", vm.node.name, vm.vmtype, vm.id)}, nil
+}
+
+func (fw *Firewall) IsCluster() bool {
+	return fw.url == clusterFirewallUrl
+}
+
+// FirewallRule - a single firewall rule
+// Pos, Digest and IPVersion are set by PVE and ignored on create
+type FirewallRule struct {
+	Pos       int     `json:"pos"`
+	Type      string  `json:"type"`
+	Action    string  `json:"action"`
+	Enable    PVEBool `json:"enable"`
+	Iface     string  `json:"iface,omitempty"`
+	Source    string  `json:"source,omitempty"`
+	Dest      string  `json:"dest,omitempty"`
+	Proto     string  `json:"proto,omitempty"`
+	Sport     string  `json:"sport,omitempty"`
+	Dport     string  `json:"dport,omitempty"`
+	IcmpType  string  `json:"icmp-type,omitempty"`
+	Macro     string  `json:"macro,omitempty"`
+	Log       string  `json:"log,omitempty"`
+	Comment   string  `json:"comment,omitempty"`
+	IPVersion int     `json:"ipversion,omitempty"`
+	Digest    string  `json:"digest,omitempty"`
+}
+
+// Params - the rule as parameters for the create and update endpoints
+func (rule FirewallRule) Params() map[string]interface{} {
+	params := map[string]interface{}{
+		"type":   rule.Type,
+		"action": rule.Action,
+		"enable": rule.Enable,
+	}
+
+	optional := map[string]string{
+		"iface":     rule.Iface,
+		"source":    rule.Source,
+		"dest":      rule.Dest,
+		"proto":     rule.Proto,
+		"sport":     rule.Sport,
+		"dport":     rule.Dport,
+		"icmp-type": rule.IcmpType,
+		"macro":     rule.Macro,
+		"log":       rule.Log,
+		"comment":   rule.Comment,
+	}
+	for k, v := range optional {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	return params
+}
+
+// Equal - compare the user settable fields of two rules, ignoring positions
+func (rule FirewallRule) Equal(other FirewallRule) bool {
+	normLog := func(l string) string {
+		if l == "nolog" {
+			return ""
+		}
+		return l
+	}
+
+	return rule.Type == other.Type &&
+		rule.Action == other.Action &&
+		rule.Enable == other.Enable &&
+		rule.Iface == other.Iface &&
+		rule.Source == other.Source &&
+		rule.Dest == other.Dest &&
+		rule.Proto == other.Proto &&
+		rule.Sport == other.Sport &&
+		rule.Dport == other.Dport &&
+		rule.IcmpType == other.IcmpType &&
+		rule.Macro == other.Macro &&
+		normLog(rule.Log) == normLog(other.Log) &&
+		rule.Comment == other.Comment
+}
+
+// FirewallRules - an ordered rule list, either the rules of a firewall
+// or the rules of a cluster security group
+type FirewallRules struct {
+	url string
+}
+
+func (fw *Firewall) Rules() *FirewallRules {
+	return &FirewallRules{url: fw.url + "/rules"}
+}
+
+func (fw *Firewall) SecurityGroupRules(group string) (rules *FirewallRules, err error) {
+	if !fw.IsCluster() {
+		return nil, errors.New("Security groups are only defined at the cluster level")
+	}
+
+	return &FirewallRules{url: fmt.Sprintf("%s/groups/%s", fw.url, group)}, nil
+}
+
+func (rules *FirewallRules) GetList() (list []FirewallRule, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(rules.url, nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	// PVE returns the list ordered, but don't rely on it
+	sort.Slice(list, func(i, j int) bool { return list[i].Pos < list[j].Pos })
+
+	return
+}
+
+func (rules *FirewallRules) Get(pos int) (rule *FirewallRule, err error) {
+	var resp *http.Response
+	url := fmt.Sprintf("%s/%d", rules.url, pos)
+	if resp, err = GetClient().session.Get(url, nil, nil); err == nil {
+		rule = &FirewallRule{}
+		err = DataResponse(resp, rule)
+	}
+
+	return
+}
+
+// Create - insert a rule at position pos, a negative pos appends at the end
+func (rules *FirewallRules) Create(rule FirewallRule, pos int) (err error) {
+	params := rule.Params()
+	if pos >= 0 {
+		params["pos"] = pos
+	}
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Post(rules.url, nil, nil, &reqbody)
+
+	return
+}
+
+// Update - replace the rule at position pos, unsetting any empty field
+func (rules *FirewallRules) Update(pos int, rule FirewallRule) (err error) {
+	params := rule.Params()
+
+	deleteKeys := ""
+	for _, k := range []string{"iface", "source", "dest", "proto", "sport", "dport", "icmp-type", "macro", "log", "comment"} {
+		if _, isSet := params[k]; !isSet {
+			if deleteKeys != "" {
+				deleteKeys += ","
+			}
+			deleteKeys += k
+		}
+	}
+	if deleteKeys != "" {
+		params["delete"] = deleteKeys
+	}
+
+	reqbody := ParamsToBody(params)
+	url := fmt.Sprintf("%s/%d", rules.url, pos)
+	_, err = GetClient().session.Put(url, nil, nil, &reqbody)
+
+	return
+}
+
+// Move - change the position of a rule
+func (rules *FirewallRules) Move(pos int, moveto int) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"moveto": moveto})
+	url := fmt.Sprintf("%s/%d", rules.url, pos)
+	_, err = GetClient().session.Put(url, nil, nil, &reqbody)
+
+	return
+}
+
+func (rules *FirewallRules) Delete(pos int) (err error) {
+	url := fmt.Sprintf("%s/%d", rules.url, pos)
+	_, err = GetClient().session.Delete(url, nil, nil)
+
+	return
+}
+
+// FirewallRuleDiff - the changes needed to turn a rule list into another
+// Delete holds positions in the current list, from the bottom up
+// Create holds rules with Pos set to their position in the desired list,
+// from the top down
+type FirewallRuleDiff struct {
+	Delete []int
+	Create []FirewallRule
+}
+
+func (diff FirewallRuleDiff) Empty() bool {
+	return len(diff.Delete) == 0 && len(diff.Create) == 0
+}
+
+// DiffFirewallRules - compute the minimal set of deletions and insertions
+// that turns current into desired. The rules common to both lists are found
+// as their longest common subsequence, so they are kept untouched in place.
+func DiffFirewallRules(current []FirewallRule, desired []FirewallRule) (diff FirewallRuleDiff) {
+	n, m := len(current), len(desired)
+
+	// lcs[i][j] is the LCS length of current[i:] and desired[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if current[i].Equal(desired[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && current[i].Equal(desired[j]):
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+			rule := desired[j]
+			rule.Pos = j
+			diff.Create = append(diff.Create, rule)
+			j++
+		default:
+			diff.Delete = append([]int{i}, diff.Delete...)
+			i++
+		}
+	}
+
+	return
+}
+
+// Sync - make the rule list equal to desired, applying only the difference
+func (rules *FirewallRules) Sync(desired []FirewallRule) (diff FirewallRuleDiff, err error) {
+	var current []FirewallRule
+	if current, err = rules.GetList(); err != nil {
+		return
+	}
+
+	diff = DiffFirewallRules(current, desired)
+
+	// deleting from the bottom up keeps the remaining positions valid, and
+	// once done the kept rules are in the desired relative order, so inserting
+	// from the top down at the desired positions yields the desired list
+	for _, pos := range diff.Delete {
+		if err = rules.Delete(pos); err != nil {
+			return
+		}
+	}
+	for _, rule := range diff.Create {
+		if err = rules.Create(rule, rule.Pos); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// FirewallSecurityGroup - a named cluster rule set that can be referenced
+// from rules of type "group"
+type FirewallSecurityGroup struct {
+	Group   string `json:"group"`
+	Comment string `json:"comment,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+func (fw *Firewall) GetSecurityGroupList() (list []FirewallSecurityGroup, err error) {
+	if !fw.IsCluster() {
+		return nil, errors.New("Security groups are only defined at the cluster level")
+	}
+
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(fw.url+"/groups", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func (fw *Firewall) CreateSecurityGroup(group string, comment string) (err error) {
+	if !fw.IsCluster() {
+		return errors.New("Security groups are only defined at the cluster level")
+	}
+
+	reqbody := ParamsToBody(map[string]interface{}{"group": group, "comment": comment})
+	_, err = GetClient().session.Post(fw.url+"/groups", nil, nil, &reqbody)
+
+	return
+}
+
+// DeleteSecurityGroup - PVE refuses to delete groups that still have rules
+func (fw *Firewall) DeleteSecurityGroup(group string) (err error) {
+	if !fw.IsCluster() {
+		return errors.New("Security groups are only defined at the cluster level")
+	}
+
+	_, err = GetClient().session.Delete(fmt.Sprintf("%s/groups/%s", fw.url, group), nil, nil)
+
+	return
+}
+
+// FirewallIPSet - a named list of addresses and networks
+type FirewallIPSet struct {
+	Name    string `json:"name"`
+	Comment string `json:"comment,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+// FirewallIPSetEntry - an IP set member, nomatch entries are excluded
+type FirewallIPSetEntry struct {
+	Cidr    string  `json:"cidr"`
+	NoMatch PVEBool `json:"nomatch"`
+	Comment string  `json:"comment,omitempty"`
+	Digest  string  `json:"digest,omitempty"`
+}
+
+func (fw *Firewall) GetIPSetList() (list []FirewallIPSet, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(fw.url+"/ipset", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func (fw *Firewall) CreateIPSet(name string, comment string) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{"name": name, "comment": comment})
+	_, err = GetClient().session.Post(fw.url+"/ipset", nil, nil, &reqbody)
+
+	return
+}
+
+// DeleteIPSet - PVE refuses to delete IP sets that still have entries
+func (fw *Firewall) DeleteIPSet(name string) (err error) {
+	_, err = GetClient().session.Delete(fmt.Sprintf("%s/ipset/%s", fw.url, name), nil, nil)
+	return
+}
+
+func (fw *Firewall) GetIPSetEntries(name string) (list []FirewallIPSetEntry, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(fmt.Sprintf("%s/ipset/%s", fw.url, name), nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func (fw *Firewall) AddIPSetEntry(name string, entry FirewallIPSetEntry) (err error) {
+	params := map[string]interface{}{
+		"cidr":    entry.Cidr,
+		"nomatch": entry.NoMatch,
+	}
+	if entry.Comment != "" {
+		params["comment"] = entry.Comment
+	}
+
+	reqbody := ParamsToBody(params)
+	_, err = GetClient().session.Post(fmt.Sprintf("%s/ipset/%s", fw.url, name), nil, nil, &reqbody)
+
+	return
+}
+
+func (fw *Firewall) DeleteIPSetEntry(name string, cidr string) (err error) {
+	cidr = url.PathEscape(cidr)
+	_, err = GetClient().session.Delete(fmt.Sprintf("%s/ipset/%s/%s", fw.url, name, cidr), nil, nil)
+
+	return
+}
+
+// FirewallAlias - a name for an address or network, usable in rules
+type FirewallAlias struct {
+	Name    string `json:"name"`
+	Cidr    string `json:"cidr"`
+	Comment string `json:"comment,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+func (fw *Firewall) GetAliasList() (list []FirewallAlias, err error) {
+	var resp *http.Response
+	if resp, err = GetClient().session.Get(fw.url+"/aliases", nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+func (fw *Firewall) CreateAlias(alias FirewallAlias) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{
+		"name":    alias.Name,
+		"cidr":    alias.Cidr,
+		"comment": alias.Comment,
+	})
+	_, err = GetClient().session.Post(fw.url+"/aliases", nil, nil, &reqbody)
+
+	return
+}
+
+func (fw *Firewall) UpdateAlias(alias FirewallAlias) (err error) {
+	reqbody := ParamsToBody(map[string]interface{}{
+		"cidr":    alias.Cidr,
+		"comment": alias.Comment,
+	})
+	_, err = GetClient().session.Put(fmt.Sprintf("%s/aliases/%s", fw.url, alias.Name), nil, nil, &reqbody)
+
+	return
+}
+
+func (fw *Firewall) DeleteAlias(name string) (err error) {
+	_, err = GetClient().session.Delete(fmt.Sprintf("%s/aliases/%s", fw.url, name), nil, nil)
+	return
+}
+
+// GetOptions - the available options depend on the scope, see the PVE API
+// documentation for each of the firewall/options endpoints
+func (fw *Firewall) GetOptions() (options map[string]interface{}, err error) {
+	var resp map[string]interface{}
+	if err = GetClient().GetJsonRetryable(fw.url+"/options", &resp, 3); err == nil {
+		if resp["data"] == nil {
+			return nil, errors.New("Firewall options could not be read")
+		}
+		options = resp["data"].(map[string]interface{})
+	}
+
+	return
+}
+
+func (fw *Firewall) SetOptions(options map[string]interface{}) (err error) {
+	reqbody := ParamsToBody(options)
+	_, err = GetClient().session.Put(fw.url+"/options", nil, nil, &reqbody)
+
+	return
+}
+
+// Enable - shortcut to toggle the "enable" option
+func (fw *Firewall) Enable(enable bool) (err error) {
+	return fw.SetOptions(map[string]interface{}{"enable": enable})
+}
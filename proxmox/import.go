@@ -0,0 +1,146 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	rxUnusedDisk = regexp.MustCompile(`^unused\d+$`)
+	rxDiskSlot   = regexp.MustCompile(`^(ide|sata|scsi|virtio)\d+$`)
+)
+
+// slots the images are imported through, they can't be attached while the
+// VM runs so the VM has to be stopped
+var importSlots = []string{"sata0", "sata1", "sata2", "sata3", "sata4", "sata5"}
+
+// ImportDisk - import an image as an unused disk of the VM, in storage
+// The source is a volume like "local:iso/image.qcow2" or an absolute path on
+// the node, format is the format of the new disk (pass "" for the storage
+// default). PVE 7.2+ is needed, and as the API can't create unused disks
+// directly the image goes through a free sata slot which is then detached,
+// so the VM has to be stopped. Returns the unusedN key and the volume, to
+// pass to AttachDisk
+func (vm *Vm) ImportDisk(source, storage, format string) (unused string, volid string, err error) {
+	if err = vm.checkImport(); err != nil {
+		return
+	}
+
+	var vmState map[string]interface{}
+	if vmState, err = vm.GetStatus(); err != nil {
+		return
+	}
+	if vmState["status"] != "stopped" {
+		return "", "", fmt.Errorf("VM %d must be stopped to import disks, it's %v", vm.id, vmState["status"])
+	}
+
+	var vmConfig map[string]interface{}
+	if vmConfig, err = vm.GetConfig(); err != nil {
+		return
+	}
+
+	slot := ""
+	for _, candidate := range importSlots {
+		if _, isSet := vmConfig[candidate]; !isSet {
+			slot = candidate
+			break
+		}
+	}
+	if slot == "" {
+		return "", "", fmt.Errorf("VM %d has no free sata slot to import the image through", vm.id)
+	}
+
+	diskConf := fmt.Sprintf("%s:0,import-from=%s", storage, source)
+	if format != "" {
+		diskConf += ",format=" + format
+	}
+
+	var exitStatus string
+	if exitStatus, err = vm.SetConfig(map[string]interface{}{slot: diskConf}); err != nil {
+		return "", "", fmt.Errorf("Error importing %s into VM %d: %v, error status: %s", source, vm.id, err, exitStatus)
+	}
+	if exitStatus != exitStatusSuccess {
+		return "", "", fmt.Errorf("Error importing %s into VM %d, error status: %s", source, vm.id, exitStatus)
+	}
+
+	if vmConfig, err = vm.GetConfig(); err != nil {
+		return
+	}
+	imported, _ := vmConfig[slot].(string)
+	if volid = strings.SplitN(imported, ",", 2)[0]; volid == "" {
+		return "", "", fmt.Errorf("Imported disk of VM %d not found in %s", vm.id, slot)
+	}
+
+	// detaching a disk leaves it as unused
+	if exitStatus, err = vm.SetConfig(map[string]interface{}{"delete": slot}); err != nil {
+		return "", volid, fmt.Errorf("Error detaching %s of VM %d: %v, error status: %s", slot, vm.id, err, exitStatus)
+	}
+	if exitStatus != exitStatusSuccess {
+		return "", volid, fmt.Errorf("Error detaching %s of VM %d, error status: %s", slot, vm.id, exitStatus)
+	}
+
+	if unused, err = vm.findUnusedDisk(volid); err == nil && unused == "" {
+		err = fmt.Errorf("Imported disk %s not found as unused in VM %d", volid, vm.id)
+	}
+
+	return
+}
+
+// AttachDisk - assign a disk to a bus slot like scsi0, the disk is an
+// unusedN key or its volume. options are more disk options as in
+// "cache=writeback,discard=on" (pass "" for none)
+func (vm *Vm) AttachDisk(disk, slot, options string) (exitStatus string, err error) {
+	if err = vm.checkImport(); err != nil {
+		return
+	}
+	if !rxDiskSlot.MatchString(slot) {
+		return "", fmt.Errorf("Invalid disk slot '%s'", slot)
+	}
+
+	volid := disk
+	if rxUnusedDisk.MatchString(disk) {
+		var vmConfig map[string]interface{}
+		if vmConfig, err = vm.GetConfig(); err != nil {
+			return
+		}
+		if volid, _ = vmConfig[disk].(string); volid == "" {
+			return "", fmt.Errorf("VM %d has no disk %s", vm.id, disk)
+		}
+	}
+
+	diskConf := volid
+	if options != "" {
+		diskConf += "," + options
+	}
+
+	return vm.SetConfig(map[string]interface{}{slot: diskConf})
+}
+
+func (vm *Vm) checkImport() (err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+	if vm.vmtype != "qemu" {
+		return errors.New("Importing disks is only supported on qemu VMs")
+	}
+
+	return
+}
+
+// findUnusedDisk - the unusedN key of volid, empty if it isn't unused
+func (vm *Vm) findUnusedDisk(volid string) (unused string, err error) {
+	var vmConfig map[string]interface{}
+	if vmConfig, err = vm.GetConfig(); err != nil {
+		return
+	}
+
+	for key, value := range vmConfig {
+		if rxUnusedDisk.MatchString(key) && value == volid {
+			return key, nil
+		}
+	}
+
+	return
+}
@@ -0,0 +1,196 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MigrateOptions - options to migrate a guest to another node
+type MigrateOptions struct {
+	Target string `json:"target"`
+	// live migration, VMs only. Running CTs are migrated with Restart
+	Online bool `json:"online,omitempty"`
+	// migrate local disks of running VMs too
+	WithLocalDisks bool `json:"with-local-disks,omitempty"`
+	// source storage to target storage, the "" key maps every storage not
+	// mapped otherwise
+	TargetStorage map[string]string `json:"targetstorage,omitempty"`
+	Bwlimit       int               `json:"bwlimit,omitempty"`
+	// CIDR of the network to migrate over, VMs only
+	MigrationNetwork string `json:"migration_network,omitempty"`
+	// shut down running CTs, migrate and start them again, Timeout is the
+	// shutdown timeout in seconds
+	Restart bool `json:"restart,omitempty"`
+	Timeout int  `json:"timeout,omitempty"`
+}
+
+// MigratePrecondition - what PVE reports about migrating a VM
+type MigratePrecondition struct {
+	Running         PVEBool                           `json:"running"`
+	AllowedNodes    []string                          `json:"allowed_nodes"`
+	NotAllowedNodes map[string]MigrateNodeRestriction `json:"not_allowed_nodes"`
+	LocalDisks      []MigrateLocalDisk                `json:"local_disks"`
+	LocalResources  []string                          `json:"local_resources"`
+}
+
+type MigrateNodeRestriction struct {
+	UnavailableStorages []string `json:"unavailable_storages"`
+}
+
+type MigrateLocalDisk struct {
+	Volid    string  `json:"volid"`
+	Size     int64   `json:"size"`
+	Cdrom    PVEBool `json:"cdrom"`
+	IsUnused PVEBool `json:"is_unused"`
+}
+
+func (opts MigrateOptions) Validate(vmtype string) error {
+	if opts.Target == "" {
+		return errors.New("Migration target node is required")
+	}
+	if opts.Bwlimit < 0 || opts.Timeout < 0 {
+		return errors.New("Migration bandwidth limit and timeout can't be negative")
+	}
+
+	if vmtype == "qemu" {
+		if opts.Restart || opts.Timeout > 0 {
+			return errors.New("Restart migrations are only supported by CTs")
+		}
+	} else {
+		if opts.Online {
+			return errors.New("CTs can't be migrated online, use Restart")
+		}
+		if opts.WithLocalDisks || opts.MigrationNetwork != "" {
+			return errors.New("Local disks and migration network options are only supported by VMs")
+		}
+	}
+
+	return nil
+}
+
+// targetStorageParam - the storage mapping in the PVE format, a bare
+// storage for the "" key and source:target pairs for the rest
+func (opts MigrateOptions) targetStorageParam() string {
+	mappings := []string{}
+	for source, target := range opts.TargetStorage {
+		if source == "" {
+			mappings = append(mappings, target)
+		} else {
+			mappings = append(mappings, source+":"+target)
+		}
+	}
+	sort.Strings(mappings)
+
+	return strings.Join(mappings, ",")
+}
+
+func (opts MigrateOptions) Params(vmtype string) map[string]interface{} {
+	params := map[string]interface{}{"target": opts.Target}
+
+	if len(opts.TargetStorage) > 0 {
+		if vmtype == "qemu" {
+			params["targetstorage"] = opts.targetStorageParam()
+		} else {
+			params["target-storage"] = opts.targetStorageParam()
+		}
+	}
+	if opts.Online {
+		params["online"] = true
+	}
+	if opts.WithLocalDisks {
+		params["with-local-disks"] = true
+	}
+	if opts.Bwlimit > 0 {
+		params["bwlimit"] = opts.Bwlimit
+	}
+	if opts.MigrationNetwork != "" {
+		params["migration_network"] = opts.MigrationNetwork
+	}
+	if opts.Restart {
+		params["restart"] = true
+	}
+	if opts.Timeout > 0 {
+		params["timeout"] = opts.Timeout
+	}
+
+	return params
+}
+
+// GetMigratePrecondition - ask PVE whether the VM can be migrated to target,
+// only VMs support this
+func (vm *Vm) GetMigratePrecondition(target string) (pre *MigratePrecondition, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	if vm.vmtype != "qemu" {
+		return nil, errors.New("Migration preconditions are only available for VMs")
+	}
+
+	var params *url.Values
+	if target != "" {
+		params = &url.Values{}
+		params.Set("target", target)
+	}
+
+	var resp *http.Response
+	url := fmt.Sprintf("/nodes/%s/qemu/%d/migrate", vm.node.name, vm.id)
+	if resp, err = GetClient().session.Get(url, params, nil); err == nil {
+		pre = &MigratePrecondition{}
+		err = DataResponse(resp, pre)
+	}
+
+	return
+}
+
+// checkMigration - fail early with the reason PVE would fail the task for
+func (vm *Vm) checkMigration(opts MigrateOptions) (err error) {
+	if vm.vmtype != "qemu" {
+		var vmState map[string]interface{}
+		if vmState, err = vm.GetStatus(); err != nil {
+			return
+		}
+		if vmState["status"] == "running" && !opts.Restart {
+			return errors.New("CT is running, set Restart to migrate it")
+		}
+		return
+	}
+
+	// without a target PVE also reports which nodes can take the VM
+	var pre *MigratePrecondition
+	if pre, err = vm.GetMigratePrecondition(""); err != nil {
+		return
+	}
+
+	if bool(pre.Running) && !opts.Online {
+		return errors.New("VM is running, set Online to migrate it")
+	}
+
+	if len(pre.LocalResources) > 0 {
+		return fmt.Errorf("VM uses local resources (%s) and can't be migrated", strings.Join(pre.LocalResources, ", "))
+	}
+
+	for _, disk := range pre.LocalDisks {
+		if disk.Cdrom && !disk.IsUnused {
+			return fmt.Errorf("VM has a local CD-ROM (%s), remove it before migrating", disk.Volid)
+		}
+		if bool(pre.Running) && !opts.WithLocalDisks {
+			return fmt.Errorf("VM has local disks (%s), set WithLocalDisks to migrate it online", disk.Volid)
+		}
+	}
+
+	knownNodes := len(pre.AllowedNodes) > 0 || len(pre.NotAllowedNodes) > 0
+	if knownNodes && len(opts.TargetStorage) == 0 && !inArray(pre.AllowedNodes, opts.Target) {
+		if restriction, isSet := pre.NotAllowedNodes[opts.Target]; isSet && len(restriction.UnavailableStorages) > 0 {
+			return fmt.Errorf("Storages %s are not available on node '%s', map them with TargetStorage",
+				strings.Join(restriction.UnavailableStorages, ", "), opts.Target)
+		}
+		return fmt.Errorf("VM can't be migrated to node '%s'", opts.Target)
+	}
+
+	return
+}
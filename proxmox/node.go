@@ -25,6 +25,23 @@ func GetNodeList() (list map[string]interface{}, err error) {
 	return
 }
 
+// GetStorageList - storages available on the node, optionally only those
+// enabled for a content type (pass "" for all)
+func (node *Node) GetStorageList(content string) (list []interface{}, err error) {
+	var resp map[string]interface{}
+
+	url := fmt.Sprintf("/nodes/%s/storage?enabled=1", node.name)
+	if content != "" {
+		url += "&content=" + content
+	}
+
+	if err = GetClient().GetJsonRetryable(url, &resp, 3); err == nil {
+		list = resp["data"].([]interface{})
+	}
+
+	return
+}
+
 // factory by name
 // getInfo for nodes already looks up by name, so use that
 func FindNode(name string) (node *Node, err error) {
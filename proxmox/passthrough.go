@@ -0,0 +1,323 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// PCIPassthrough - a host PCI device passed to the VM, PVE's hostpciN
+// option. Host is the device id as in "0000:01:00.0", or "01:00" for all
+// its functions, several ids go separated by ';'. Mapping is a cluster
+// resource mapping to use instead of Host. Rombar is on when unset, Pcie
+// needs the q35 machine type and XVga makes the device the primary GPU.
+// Mdev is the mediated device type for vGPUs. Options has the options
+// without a field, which are kept as they are
+type PCIPassthrough struct {
+	Host        string            `json:"host,omitempty"`
+	Mapping     string            `json:"mapping,omitempty"`
+	Pcie        bool              `json:"pcie,omitempty"`
+	Rombar      *bool             `json:"rombar,omitempty"`
+	Romfile     string            `json:"romfile,omitempty"`
+	XVga        bool              `json:"x-vga,omitempty"`
+	Mdev        string            `json:"mdev,omitempty"`
+	LegacyIgd   bool              `json:"legacy-igd,omitempty"`
+	VendorId    string            `json:"vendor-id,omitempty"`
+	DeviceId    string            `json:"device-id,omitempty"`
+	SubVendorId string            `json:"sub-vendor-id,omitempty"`
+	SubDeviceId string            `json:"sub-device-id,omitempty"`
+	Options     map[string]string `json:"options,omitempty"`
+}
+
+var rxPCIHost = regexp.MustCompile(`^([0-9a-fA-F]{4}:)?[0-9a-fA-F]{2}:[0-9a-fA-F]{2}(\.[0-7])?$`)
+
+// ParsePCIPassthrough - parse the PVE hostpciN syntax, not validated as it's
+// also used for the API values
+func ParsePCIPassthrough(hostpci string) (pci PCIPassthrough, err error) {
+	for i, item := range strings.Split(hostpci, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 1 && i == 0 {
+			kv = []string{"host", item}
+		}
+		if len(kv) != 2 {
+			return pci, fmt.Errorf("Invalid hostpci option '%s'", item)
+		}
+
+		switch kv[0] {
+		case "host":
+			pci.Host = kv[1]
+		case "mapping":
+			pci.Mapping = kv[1]
+		case "pcie":
+			pci.Pcie = kv[1] == "1"
+		case "rombar":
+			rombar := kv[1] == "1"
+			pci.Rombar = &rombar
+		case "romfile":
+			pci.Romfile = kv[1]
+		case "x-vga":
+			pci.XVga = kv[1] == "1"
+		case "mdev":
+			pci.Mdev = kv[1]
+		case "legacy-igd":
+			pci.LegacyIgd = kv[1] == "1"
+		case "vendor-id":
+			pci.VendorId = kv[1]
+		case "device-id":
+			pci.DeviceId = kv[1]
+		case "sub-vendor-id":
+			pci.SubVendorId = kv[1]
+		case "sub-device-id":
+			pci.SubDeviceId = kv[1]
+		default:
+			if pci.Options == nil {
+				pci.Options = map[string]string{}
+			}
+			pci.Options[kv[0]] = kv[1]
+		}
+	}
+
+	return
+}
+
+func (pci PCIPassthrough) Validate() error {
+	if (pci.Host == "") == (pci.Mapping == "") {
+		return fmt.Errorf("A hostpci device needs either a host or a mapping")
+	}
+	if pci.Host == "" {
+		return nil
+	}
+	for _, host := range strings.Split(pci.Host, ";") {
+		if !rxPCIHost.MatchString(host) {
+			return fmt.Errorf("Invalid hostpci host '%s'", host)
+		}
+	}
+
+	return nil
+}
+
+// String - the PVE hostpciN option syntax
+func (pci PCIPassthrough) String() string {
+	items := []string{}
+	if pci.Host != "" {
+		items = append(items, "host="+pci.Host)
+	}
+	if pci.Mapping != "" {
+		items = append(items, "mapping="+pci.Mapping)
+	}
+	if pci.Pcie {
+		items = append(items, "pcie=1")
+	}
+	if pci.Rombar != nil {
+		rombar := "0"
+		if *pci.Rombar {
+			rombar = "1"
+		}
+		items = append(items, "rombar="+rombar)
+	}
+	if pci.Romfile != "" {
+		items = append(items, "romfile="+pci.Romfile)
+	}
+	if pci.XVga {
+		items = append(items, "x-vga=1")
+	}
+	if pci.Mdev != "" {
+		items = append(items, "mdev="+pci.Mdev)
+	}
+	if pci.LegacyIgd {
+		items = append(items, "legacy-igd=1")
+	}
+	if pci.VendorId != "" {
+		items = append(items, "vendor-id="+pci.VendorId)
+	}
+	if pci.DeviceId != "" {
+		items = append(items, "device-id="+pci.DeviceId)
+	}
+	if pci.SubVendorId != "" {
+		items = append(items, "sub-vendor-id="+pci.SubVendorId)
+	}
+	if pci.SubDeviceId != "" {
+		items = append(items, "sub-device-id="+pci.SubDeviceId)
+	}
+	items = append(items, optionItems(pci.Options)...)
+
+	return strings.Join(items, ",")
+}
+
+// the JSON form is the PVE syntax, an object with the fields is accepted too
+func (pci PCIPassthrough) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pci.String())
+}
+
+func (pci *PCIPassthrough) UnmarshalJSON(b []byte) (err error) {
+	var hostpci string
+	if err = json.Unmarshal(b, &hostpci); err == nil {
+		if *pci, err = ParsePCIPassthrough(hostpci); err == nil {
+			err = pci.Validate()
+		}
+		return
+	}
+
+	type fields PCIPassthrough
+	if err = json.Unmarshal(b, (*fields)(pci)); err != nil {
+		return
+	}
+	return pci.Validate()
+}
+
+// USBPassthrough - a host USB device passed to the VM, PVE's usbN option.
+// Host is the device as vendor:product ("046d:c52b"), the port it's plugged
+// to ("1-2.3") or "spice" for SPICE USB redirection. Mapping is a cluster
+// resource mapping to use instead of Host. Options has the options without a
+// field, which are kept as they are
+type USBPassthrough struct {
+	Host    string            `json:"host,omitempty"`
+	Mapping string            `json:"mapping,omitempty"`
+	Usb3    bool              `json:"usb3,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+var rxUSBHost = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{4}:(0x)?[0-9a-fA-F]{4}$|^\d+-\d+(\.\d+)*$|^spice$`)
+
+// ParseUSBPassthrough - parse the PVE usbN syntax, not validated as it's also
+// used for the API values
+func ParseUSBPassthrough(usb string) (device USBPassthrough, err error) {
+	for i, item := range strings.Split(usb, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 1 && i == 0 {
+			kv = []string{"host", item}
+		}
+		if len(kv) != 2 {
+			return device, fmt.Errorf("Invalid usb option '%s'", item)
+		}
+
+		switch kv[0] {
+		case "host":
+			device.Host = kv[1]
+		case "mapping":
+			device.Mapping = kv[1]
+		case "usb3":
+			device.Usb3 = kv[1] == "1"
+		default:
+			if device.Options == nil {
+				device.Options = map[string]string{}
+			}
+			device.Options[kv[0]] = kv[1]
+		}
+	}
+
+	return
+}
+
+func (device USBPassthrough) Validate() error {
+	if (device.Host == "") == (device.Mapping == "") {
+		return fmt.Errorf("A usb device needs either a host or a mapping")
+	}
+	if device.Host != "" && !rxUSBHost.MatchString(device.Host) {
+		return fmt.Errorf("Invalid usb host '%s'", device.Host)
+	}
+
+	return nil
+}
+
+// String - the PVE usbN option syntax
+func (device USBPassthrough) String() string {
+	items := []string{}
+	if device.Host != "" {
+		items = append(items, "host="+device.Host)
+	}
+	if device.Mapping != "" {
+		items = append(items, "mapping="+device.Mapping)
+	}
+	if device.Usb3 {
+		items = append(items, "usb3=1")
+	}
+	items = append(items, optionItems(device.Options)...)
+
+	return strings.Join(items, ",")
+}
+
+// the JSON form is the PVE syntax, an object with the fields is accepted too
+func (device USBPassthrough) MarshalJSON() ([]byte, error) {
+	return json.Marshal(device.String())
+}
+
+func (device *USBPassthrough) UnmarshalJSON(b []byte) (err error) {
+	var usb string
+	if err = json.Unmarshal(b, &usb); err == nil {
+		if *device, err = ParseUSBPassthrough(usb); err == nil {
+			err = device.Validate()
+		}
+		return
+	}
+
+	type fields USBPassthrough
+	if err = json.Unmarshal(b, (*fields)(device)); err != nil {
+		return
+	}
+	return device.Validate()
+}
+
+// NodePCIDevice - a PCI device of the node, the ids are hex numbers as in
+// "0x10de". The Id is what goes in PCIPassthrough.Host
+type NodePCIDevice struct {
+	Id              string  `json:"id"`
+	Class           string  `json:"class"`
+	Vendor          string  `json:"vendor"`
+	VendorName      string  `json:"vendor_name,omitempty"`
+	Device          string  `json:"device"`
+	DeviceName      string  `json:"device_name,omitempty"`
+	SubsystemVendor string  `json:"subsystem_vendor,omitempty"`
+	SubsystemDevice string  `json:"subsystem_device,omitempty"`
+	IommuGroup      int     `json:"iommugroup"`
+	Mdev            PVEBool `json:"mdev,omitempty"`
+}
+
+// ListPCIDevices - the PCI devices of the node, without the classes PVE
+// filters by default (memory controllers and bridges) unless all is set
+func (node *Node) ListPCIDevices(all bool) (list []NodePCIDevice, err error) {
+	params := url.Values{}
+	params.Set("verbose", "1")
+	if all {
+		params.Set("pci-class-blacklist", "")
+	}
+
+	var resp *http.Response
+	pciUrl := fmt.Sprintf("/nodes/%s/hardware/pci", node.name)
+	if resp, err = GetClient().session.Get(pciUrl, &params, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+// NodeUSBDevice - a USB device of the node, Vendid:Prodid or the
+// Busnum-Usbpath port is what goes in USBPassthrough.Host
+type NodeUSBDevice struct {
+	Busnum       int    `json:"busnum"`
+	Devnum       int    `json:"devnum"`
+	Port         int    `json:"port"`
+	Usbpath      string `json:"usbpath,omitempty"`
+	Level        int    `json:"level"`
+	Class        int    `json:"class"`
+	Vendid       string `json:"vendid"`
+	Prodid       string `json:"prodid"`
+	Speed        string `json:"speed"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+}
+
+// ListUSBDevices - the USB devices of the node
+func (node *Node) ListUSBDevices() (list []NodeUSBDevice, err error) {
+	var resp *http.Response
+	usbUrl := fmt.Sprintf("/nodes/%s/hardware/usb", node.name)
+	if resp, err = GetClient().session.Get(usbUrl, nil, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
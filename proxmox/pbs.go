@@ -0,0 +1,148 @@
+package proxmox
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// PBSSnapshot - a backup snapshot on a Proxmox Backup Server storage
+type PBSSnapshot struct {
+	StorageVolume
+	// the PBS namespace the storage is configured with, "" for the root
+	Namespace string
+	// vm, ct or host
+	BackupType string
+	BackupID   string
+	BackupTime time.Time
+}
+
+// Verified - whether the last verification of the snapshot succeeded
+func (snap PBSSnapshot) Verified() bool {
+	return snap.Verification != nil && snap.Verification.State == "ok"
+}
+
+// IsEncrypted - whether the snapshot is encrypted (the field holds the key
+// fingerprint, or "1" for older versions)
+func (snap PBSSnapshot) IsEncrypted() bool {
+	return snap.Encrypted != "" && snap.Encrypted != "0"
+}
+
+// PBSFileEntry - a file or directory inside a backup snapshot
+// Filepath is base64 encoded, as PVE expects it back for further browsing
+// or downloading
+type PBSFileEntry struct {
+	Filepath string  `json:"filepath"`
+	Text     string  `json:"text"`
+	Type     string  `json:"type"`
+	Leaf     PVEBool `json:"leaf"`
+	Size     int64   `json:"size,omitempty"`
+	Mtime    int64   `json:"mtime,omitempty"`
+}
+
+// Path - the decoded path of the entry
+func (entry PBSFileEntry) Path() string {
+	if path, err := base64.StdEncoding.DecodeString(entry.Filepath); err == nil {
+		return string(path)
+	}
+	return entry.Filepath
+}
+
+// IsDir - whether the entry can be listed further
+func (entry PBSFileEntry) IsDir() bool {
+	return !bool(entry.Leaf)
+}
+
+var rxPBSVolume = regexp.MustCompile(`:backup/(vm|ct|host)/([^/]+)/(.+)$`)
+
+// checkPBS - get the storage config and make sure it's a PBS storage
+func (storage *Storage) checkPBS() (storageInfo map[string]interface{}, err error) {
+	if storageInfo, err = storage.GetInfo(); err != nil {
+		return
+	}
+	if storageInfo["type"] != "pbs" {
+		return nil, fmt.Errorf("Storage '%s' is not a Proxmox Backup Server storage", storage.name)
+	}
+
+	return
+}
+
+// GetPBSSnapshotList - backup snapshots in a PBS storage, as seen from node,
+// with vmid > 0 only those of that guest are listed
+func (storage *Storage) GetPBSSnapshotList(node *Node, vmid int) (list []PBSSnapshot, err error) {
+	var storageInfo map[string]interface{}
+	if storageInfo, err = storage.checkPBS(); err != nil {
+		return
+	}
+
+	namespace := ""
+	if ns, isSet := storageInfo["namespace"]; isSet {
+		namespace = ns.(string)
+	}
+
+	var volumes []StorageVolume
+	if volumes, err = storage.GetContent(node, "backup", vmid); err != nil {
+		return
+	}
+
+	for _, volume := range volumes {
+		snap := PBSSnapshot{StorageVolume: volume, Namespace: namespace}
+		if match := rxPBSVolume.FindStringSubmatch(volume.Volid); match != nil {
+			snap.BackupType, snap.BackupID = match[1], match[2]
+			snap.BackupTime, _ = time.Parse(time.RFC3339, match[3])
+		}
+		list = append(list, snap)
+	}
+
+	return
+}
+
+// fileRestoreParams - filepath is base64 encoded, "" means the root
+func fileRestoreParams(volid string, filepath string) *url.Values {
+	if filepath == "" {
+		filepath = base64.StdEncoding.EncodeToString([]byte("/"))
+	}
+
+	params := url.Values{}
+	params.Set("volume", volid)
+	params.Set("filepath", filepath)
+
+	return &params
+}
+
+// ListSnapshotFiles - browse the contents of a backup snapshot, filepath is
+// the (base64 encoded) Filepath of a previous entry, or "" for the root
+func (storage *Storage) ListSnapshotFiles(node *Node, volid string, filepath string) (list []PBSFileEntry, err error) {
+	if _, err = storage.checkPBS(); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	url := fmt.Sprintf("/nodes/%s/storage/%s/file-restore/list", node.name, storage.name)
+	if resp, err = GetClient().session.Get(url, fileRestoreParams(volid, filepath), nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
+
+// DownloadSnapshotFile - write the contents of a file in a backup snapshot to
+// w, directories are downloaded as a zip archive
+func (storage *Storage) DownloadSnapshotFile(node *Node, volid string, filepath string, w io.Writer) (written int64, err error) {
+	if _, err = storage.checkPBS(); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	url := fmt.Sprintf("/nodes/%s/storage/%s/file-restore/download", node.name, storage.name)
+	if resp, err = GetClient().session.Get(url, fileRestoreParams(volid, filepath), nil); err == nil {
+		defer resp.Body.Close()
+		written, err = io.Copy(w, resp.Body)
+	}
+
+	return
+}
@@ -0,0 +1,101 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Privileges - granted privilege names, mapped to whether they propagate
+type Privileges map[string]bool
+
+// PermissionError - returned when privileges needed for an operation are
+// missing on a path
+type PermissionError struct {
+	Path    string
+	Missing []string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("Missing privileges on '%s': %s", e.Path, strings.Join(e.Missing, ", "))
+}
+
+// Permissions - effective privileges of the logged in user on a path
+func (c *Client) Permissions(path string) (privs Privileges, err error) {
+	params := url.Values{}
+	params.Set("path", path)
+
+	var resp map[string]interface{}
+	if _, err = c.session.GetJSON("/access/permissions", &params, nil, &resp); err != nil {
+		return
+	}
+
+	privs = Privileges{}
+	if data, isSet := resp["data"].(map[string]interface{}); isSet {
+		if pathPrivs, isSet := data[path].(map[string]interface{}); isSet {
+			for priv, propagate := range pathPrivs {
+				privs[priv] = Itob(int(propagate.(float64)))
+			}
+		}
+	}
+
+	return
+}
+
+// CanDo - nil if the logged in user has all the privileges on path,
+// otherwise a *PermissionError listing the missing ones
+func (c *Client) CanDo(path string, privileges ...string) (err error) {
+	var privs Privileges
+	if privs, err = c.Permissions(path); err != nil {
+		return
+	}
+
+	missing := []string{}
+	for _, priv := range privileges {
+		if _, granted := privs[priv]; !granted {
+			missing = append(missing, priv)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &PermissionError{Path: path, Missing: missing}
+	}
+
+	return
+}
+
+// privilegeCheck - privileges an operation needs on a path
+type privilegeCheck struct {
+	path  string
+	privs []string
+}
+
+// preflight - when CheckPrivileges is set, verify the privileges for an
+// operation before attempting it, so it fails early and with a clear error
+// instead of a bare "403 Forbidden"
+func (c *Client) preflight(checks ...privilegeCheck) (err error) {
+	if !c.CheckPrivileges {
+		return
+	}
+
+	for _, check := range checks {
+		if err = c.CanDo(check.path, check.privs...); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// privileges needed for common guest operations
+func vmPrivilegeCheck(vmid int, privs ...string) privilegeCheck {
+	return privilegeCheck{path: fmt.Sprintf("/vms/%d", vmid), privs: privs}
+}
+
+func storagePrivilegeCheck(storage string, privs ...string) privilegeCheck {
+	return privilegeCheck{path: "/storage/" + storage, privs: privs}
+}
+
+func poolPrivilegeCheck(pool string, privs ...string) privilegeCheck {
+	return privilegeCheck{path: "/pool/" + pool, privs: privs}
+}
@@ -0,0 +1,186 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// RestoreOptions - overrides applied when restoring a backup
+type RestoreOptions struct {
+	// default storage for the restored volumes
+	Storage string `json:"storage,omitempty"`
+	// name for VMs, hostname for CTs
+	Name   string `json:"name,omitempty"`
+	Memory int    `json:"memory,omitempty"`
+	Cores  int    `json:"cores,omitempty"`
+	// regenerate MAC addresses (and the vmgenid of VMs)
+	Unique  bool   `json:"unique,omitempty"`
+	Pool    string `json:"pool,omitempty"`
+	Bwlimit int    `json:"bwlimit,omitempty"`
+	Start   bool   `json:"start,omitempty"`
+	// CT only, volume specs for rootfs and mpN, in the `storage:sizeGB` form
+	// PVE doesn't allow remapping single VM disks on restore, use Storage
+	StorageMap map[string]string `json:"storagemap,omitempty"`
+}
+
+var rxBackupType = regexp.MustCompile(`vzdump-(qemu|lxc)-\d+-|backup/(vm|ct)/\d+/`)
+
+// BackupGuestType - the guest type ("qemu" or "lxc") of a backup volume,
+// from its file name (vzdump-qemu-100-...) or its PBS path (backup/vm/100/...)
+func BackupGuestType(backupVolid string) (guestType string, err error) {
+	match := rxBackupType.FindStringSubmatch(backupVolid)
+	switch {
+	case match == nil:
+		err = fmt.Errorf("Can't tell the guest type of backup '%s'", backupVolid)
+	case match[1] != "":
+		guestType = match[1]
+	case match[2] == "vm":
+		guestType = "qemu"
+	default:
+		guestType = "lxc"
+	}
+
+	return
+}
+
+func (opts RestoreOptions) params(vmtype string, backupVolid string) (params map[string]interface{}, err error) {
+	params = map[string]interface{}{}
+
+	if vmtype == "qemu" {
+		params["archive"] = backupVolid
+		if opts.Name != "" {
+			params["name"] = opts.Name
+		}
+		if len(opts.StorageMap) > 0 {
+			return nil, errors.New("Storage mapping is only supported restoring CTs")
+		}
+	} else {
+		params["ostemplate"] = backupVolid
+		params["restore"] = true
+		if opts.Name != "" {
+			params["hostname"] = opts.Name
+		}
+		for volume, spec := range opts.StorageMap {
+			params[volume] = spec
+		}
+	}
+
+	if opts.Storage != "" {
+		params["storage"] = opts.Storage
+	}
+	if opts.Memory > 0 {
+		params["memory"] = opts.Memory
+	}
+	if opts.Cores > 0 {
+		params["cores"] = opts.Cores
+	}
+	if opts.Unique {
+		params["unique"] = true
+	}
+	if opts.Pool != "" {
+		params["pool"] = opts.Pool
+	}
+	if opts.Bwlimit > 0 {
+		params["bwlimit"] = opts.Bwlimit
+	}
+	if opts.Start {
+		params["start"] = true
+	}
+
+	return
+}
+
+// restore - create the guest from the backup, replacing it if force is set
+func (vm *Vm) restore(backupVolid string, opts RestoreOptions, force bool) (exitStatus string, err error) {
+	var params map[string]interface{}
+	if params, err = opts.params(vm.vmtype, backupVolid); err != nil {
+		return
+	}
+
+	checks := []privilegeCheck{vmPrivilegeCheck(vm.id, "VM.Allocate")}
+	if opts.Storage != "" {
+		checks = append(checks, storagePrivilegeCheck(opts.Storage, "Datastore.AllocateSpace"))
+	}
+	if opts.Pool != "" {
+		checks = append(checks, poolPrivilegeCheck(opts.Pool, "VM.Allocate"))
+	}
+	if err = GetClient().preflight(checks...); err != nil {
+		return
+	}
+
+	params["vmid"] = vm.id
+	if force {
+		params["force"] = true
+	}
+
+	reqbody := ParamsToBody(params)
+	url := fmt.Sprintf("/nodes/%s/%s", vm.node.name, vm.vmtype)
+
+	var (
+		resp         *http.Response
+		taskResponse map[string]interface{}
+	)
+
+	if resp, err = GetClient().session.Post(url, nil, nil, &reqbody); err == nil {
+		if taskResponse, err = ResponseJSON(resp); err == nil {
+			exitStatus, err = GetClient().WaitForCompletion(taskResponse)
+		}
+	}
+
+	return
+}
+
+// RestoreFrom - overwrite an existing, stopped guest with a backup of the
+// same guest type
+func (vm *Vm) RestoreFrom(backupVolid string, opts RestoreOptions) (exitStatus string, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	var guestType string
+	if guestType, err = BackupGuestType(backupVolid); err != nil {
+		return
+	}
+	if guestType != vm.vmtype {
+		return "", fmt.Errorf("Can't restore a %s backup into a %s guest", guestType, vm.vmtype)
+	}
+
+	var vmState map[string]interface{}
+	if vmState, err = vm.GetStatus(); err != nil {
+		return
+	}
+	if vmState["status"] != "stopped" {
+		return "", errors.New("VM must be stopped first")
+	}
+
+	return vm.restore(backupVolid, opts, true)
+}
+
+// RestoreNew - restore a backup into a new guest on node, with vmid <= 0
+// the next free id is used
+func RestoreNew(node *Node, vmid int, backupVolid string, storage string) (vm *Vm, exitStatus string, err error) {
+	return RestoreNewWithOptions(node, vmid, backupVolid, RestoreOptions{Storage: storage})
+}
+
+func RestoreNewWithOptions(node *Node, vmid int, backupVolid string, opts RestoreOptions) (vm *Vm, exitStatus string, err error) {
+	var guestType string
+	if guestType, err = BackupGuestType(backupVolid); err != nil {
+		return
+	}
+
+	if vmid <= 0 {
+		if vmid, err = GetNextVmId(0); err != nil {
+			return
+		}
+	}
+
+	vm = NewVm(vmid)
+	vm.SetNode(node)
+	vm.SetType(guestType)
+
+	exitStatus, err = vm.restore(backupVolid, opts, false)
+
+	return
+}
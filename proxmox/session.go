@@ -62,6 +62,18 @@ func ParamsToBody(params map[string]interface{}) (body []byte) {
 			} else {
 				v = "0"
 			}
+		case PVEBool:
+			if intrV.(PVEBool) {
+				v = "1"
+			} else {
+				v = "0"
+			}
+		// Lists are sent as the parameter repeated for each value
+		case []string:
+			for _, item := range intrV.([]string) {
+				vals.Add(k, item)
+			}
+			continue
 		default:
 			v = fmt.Sprintf("%v", intrV)
 		}
@@ -106,6 +118,24 @@ func TypedResponse(resp *http.Response, v interface{}) error {
 	return nil
 }
 
+// DataResponse - decode the "data" member of the response envelope into v
+func DataResponse(resp *http.Response, v interface{}) error {
+	var intermediate struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err := decodeResponse(resp, &intermediate)
+	if err != nil {
+		return fmt.Errorf("error reading response envelope: %v", err)
+	}
+	if len(intermediate.Data) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(intermediate.Data, v); err != nil {
+		return fmt.Errorf("error unmarshalling data %v", err)
+	}
+	return nil
+}
+
 func (s *Session) Login(username string, password string) (err error) {
 	reqbody := ParamsToBody(map[string]interface{}{"username": username, "password": password})
 	olddebug := *Debug
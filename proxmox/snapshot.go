@@ -0,0 +1,237 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// name of the pseudo-snapshot PVE lists for the current state of the guest
+const currentSnapshotName = "current"
+
+// Snapshot - a guest snapshot, as listed by PVE
+// the "current" pseudo-snapshot has Running set and its Parent is the
+// snapshot the guest state derives from
+type Snapshot struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Snaptime    int64   `json:"snaptime,omitempty"`
+	Parent      string  `json:"parent,omitempty"`
+	Vmstate     PVEBool `json:"vmstate"`
+	Running     PVEBool `json:"running"`
+
+	vm *Vm
+}
+
+// SnapshotOptions - options to create a snapshot
+// Vmstate (saving the RAM) is only supported by VMs
+type SnapshotOptions struct {
+	Name        string `json:"snapname"`
+	Description string `json:"description,omitempty"`
+	Vmstate     bool   `json:"vmstate,omitempty"`
+}
+
+func (opts SnapshotOptions) Params() map[string]interface{} {
+	params := map[string]interface{}{"snapname": opts.Name}
+	if opts.Description != "" {
+		params["description"] = opts.Description
+	}
+	if opts.Vmstate {
+		params["vmstate"] = true
+	}
+
+	return params
+}
+
+func (snap *Snapshot) Vm() *Vm {
+	return snap.vm
+}
+
+func (snap *Snapshot) IsCurrent() bool {
+	return snap.Name == currentSnapshotName
+}
+
+func (snap *Snapshot) Time() time.Time {
+	return time.Unix(snap.Snaptime, 0)
+}
+
+// GetSnapshots - typed version of GetSnapshotList, "current" included
+func (vm *Vm) GetSnapshots() (list []*Snapshot, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	url := fmt.Sprintf("/nodes/%s/%s/%d/snapshot", vm.node.name, vm.vmtype, vm.id)
+	if resp, err = GetClient().session.Get(url, nil, nil); err == nil {
+		if err = DataResponse(resp, &list); err == nil {
+			for _, snap := range list {
+				snap.vm = vm
+			}
+		}
+	}
+
+	return
+}
+
+func (vm *Vm) GetSnapshot(name string) (snap *Snapshot, err error) {
+	var list []*Snapshot
+	if list, err = vm.GetSnapshots(); err != nil {
+		return
+	}
+
+	for _, snap = range list {
+		if snap.Name == name {
+			return
+		}
+	}
+
+	return nil, fmt.Errorf("Snapshot '%s' not found", name)
+}
+
+// Update - change the snapshot description
+func (snap *Snapshot) Update(description string) (err error) {
+	if snap.IsCurrent() {
+		return fmt.Errorf("Can't update the '%s' pseudo-snapshot", currentSnapshotName)
+	}
+
+	reqbody := ParamsToBody(map[string]interface{}{"description": description})
+	url := fmt.Sprintf("/nodes/%s/%s/%d/snapshot/%s/config", snap.vm.node.name, snap.vm.vmtype, snap.vm.id, snap.Name)
+	if _, err = GetClient().session.Put(url, nil, nil, &reqbody); err == nil {
+		snap.Description = description
+	}
+
+	return
+}
+
+// SnapshotTree - a node in the snapshot hierarchy. The tree root has no
+// snapshot, it stands for the guest before any snapshot was taken
+type SnapshotTree struct {
+	Snapshot *Snapshot
+	Parent   *SnapshotTree
+	Children []*SnapshotTree
+}
+
+// NewSnapshotTree - reconstruct the hierarchy from the parent links
+// snapshots whose parent isn't in the list hang from the root, children are
+// ordered by time, with "current" last
+func NewSnapshotTree(list []*Snapshot) (root *SnapshotTree) {
+	root = &SnapshotTree{}
+
+	nodes := map[string]*SnapshotTree{}
+	for _, snap := range list {
+		nodes[snap.Name] = &SnapshotTree{Snapshot: snap}
+	}
+
+	for _, snap := range list {
+		node := nodes[snap.Name]
+		if parent, isSet := nodes[snap.Parent]; isSet && snap.Parent != snap.Name {
+			node.Parent = parent
+		} else {
+			node.Parent = root
+		}
+		node.Parent.Children = append(node.Parent.Children, node)
+	}
+
+	root.Walk(func(node *SnapshotTree, depth int) bool {
+		sort.SliceStable(node.Children, func(i, j int) bool {
+			a, b := node.Children[i].Snapshot, node.Children[j].Snapshot
+			if a.IsCurrent() != b.IsCurrent() {
+				return b.IsCurrent()
+			}
+			return a.Snaptime < b.Snaptime
+		})
+		return true
+	})
+
+	return
+}
+
+// Walk - visit the tree depth first, the root is at depth 0
+// returning false from visit skips the children of that node
+func (tree *SnapshotTree) Walk(visit func(node *SnapshotTree, depth int) bool) {
+	var walk func(node *SnapshotTree, depth int)
+	walk = func(node *SnapshotTree, depth int) {
+		if !visit(node, depth) {
+			return
+		}
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	walk(tree, 0)
+}
+
+// Find - the node of the named snapshot, nil if it's not in the tree
+func (tree *SnapshotTree) Find(name string) (found *SnapshotTree) {
+	tree.Walk(func(node *SnapshotTree, depth int) bool {
+		if node.Snapshot != nil && node.Snapshot.Name == name {
+			found = node
+		}
+		return found == nil
+	})
+
+	return
+}
+
+// Current - the node of the "current" pseudo-snapshot
+func (tree *SnapshotTree) Current() *SnapshotTree {
+	return tree.Find(currentSnapshotName)
+}
+
+// Ancestors - the snapshots from the parent of this node up to the first one
+func (tree *SnapshotTree) Ancestors() (list []*Snapshot) {
+	for node := tree.Parent; node != nil && node.Snapshot != nil; node = node.Parent {
+		list = append(list, node.Snapshot)
+	}
+
+	return
+}
+
+// Descendants - all the snapshots below this node, "current" included
+func (tree *SnapshotTree) Descendants() (list []*Snapshot) {
+	tree.Walk(func(node *SnapshotTree, depth int) bool {
+		if node != tree {
+			list = append(list, node.Snapshot)
+		}
+		return true
+	})
+
+	return
+}
+
+// String - render the tree, one snapshot per line
+func (tree *SnapshotTree) String() string {
+	var b strings.Builder
+
+	// the root has no snapshot to print, so its children go unindented
+	offset := 0
+	if tree.Snapshot == nil {
+		offset = 1
+	}
+
+	tree.Walk(func(node *SnapshotTree, depth int) bool {
+		if node.Snapshot == nil {
+			return true
+		}
+
+		snap := node.Snapshot
+		fmt.Fprintf(&b, "%s`- %s", strings.Repeat("   ", depth-offset), snap.Name)
+		if !snap.IsCurrent() {
+			fmt.Fprintf(&b, " (%s)", snap.Time().Format("2006-01-02 15:04:05"))
+		}
+		if snap.Vmstate {
+			b.WriteString(" [vmstate]")
+		}
+		if snap.Description != "" && !snap.IsCurrent() {
+			fmt.Fprintf(&b, " %s", strings.SplitN(strings.TrimSpace(snap.Description), "\n", 2)[0])
+		}
+		b.WriteString("\n")
+
+		return true
+	})
+
+	return b.String()
+}
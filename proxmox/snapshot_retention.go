@@ -0,0 +1,127 @@
+package proxmox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotRetentionPolicy - which snapshots of a guest to keep, in the
+// spirit of the PBS prune options. Each keep-* option keeps the newest
+// snapshot of that many distinct periods, skipping periods already covered
+// by a snapshot kept by a previous option. Snapshots not kept by any option
+// are deleted, unless no option is set at all, in which case all are kept.
+// Only snapshots whose name starts with Prefix are considered, the rest (and
+// the "current" pseudo-snapshot) are never touched.
+type SnapshotRetentionPolicy struct {
+	KeepLast    int    `json:"keep-last,omitempty"`
+	KeepHourly  int    `json:"keep-hourly,omitempty"`
+	KeepDaily   int    `json:"keep-daily,omitempty"`
+	KeepWeekly  int    `json:"keep-weekly,omitempty"`
+	KeepMonthly int    `json:"keep-monthly,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`
+}
+
+func (policy SnapshotRetentionPolicy) keepsAll() bool {
+	return policy.KeepLast <= 0 &&
+		policy.KeepHourly <= 0 &&
+		policy.KeepDaily <= 0 &&
+		policy.KeepWeekly <= 0 &&
+		policy.KeepMonthly <= 0
+}
+
+// Evaluate - split the snapshots the policy applies to into those to keep
+// and those to delete, both ordered newest first
+func (policy SnapshotRetentionPolicy) Evaluate(list []*Snapshot) (keep []*Snapshot, remove []*Snapshot) {
+	candidates := []*Snapshot{}
+	for _, snap := range list {
+		if !snap.IsCurrent() && strings.HasPrefix(snap.Name, policy.Prefix) {
+			candidates = append(candidates, snap)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Snaptime > candidates[j].Snaptime
+	})
+
+	if policy.keepsAll() {
+		return candidates, nil
+	}
+
+	kept := map[*Snapshot]bool{}
+
+	mark := func(count int, period func(t time.Time) string) {
+		if count <= 0 {
+			return
+		}
+
+		// periods already covered by snapshots kept by previous options
+		covered := map[string]bool{}
+		for snap := range kept {
+			covered[period(snap.Time())] = true
+		}
+
+		included := map[string]bool{}
+		for _, snap := range candidates {
+			p := period(snap.Time())
+			if kept[snap] || covered[p] || included[p] {
+				continue
+			}
+			if len(included) >= count {
+				break
+			}
+			included[p] = true
+			kept[snap] = true
+		}
+	}
+
+	for i := 0; i < policy.KeepLast && i < len(candidates); i++ {
+		kept[candidates[i]] = true
+	}
+	mark(policy.KeepHourly, func(t time.Time) string { return t.Format("2006-01-02 15") })
+	mark(policy.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	mark(policy.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d/%d", year, week)
+	})
+	mark(policy.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") })
+
+	for _, snap := range candidates {
+		if kept[snap] {
+			keep = append(keep, snap)
+		} else {
+			remove = append(remove, snap)
+		}
+	}
+
+	return
+}
+
+// ApplySnapshotRetention - delete the snapshots the policy doesn't keep,
+// oldest first and waiting for each deletion to finish. With dryRun nothing
+// is deleted, the returned list has the snapshots that would be deleted.
+func (vm *Vm) ApplySnapshotRetention(policy SnapshotRetentionPolicy, dryRun bool) (removed []*Snapshot, err error) {
+	var list []*Snapshot
+	if list, err = vm.GetSnapshots(); err != nil {
+		return
+	}
+
+	_, remove := policy.Evaluate(list)
+	if dryRun {
+		return remove, nil
+	}
+
+	for i := len(remove) - 1; i >= 0; i-- {
+		snap := remove[i]
+
+		// DeleteSnapshot waits, so the next deletion doesn't collide with the
+		// snapshot lock
+		if _, err = vm.DeleteSnapshot(snap.Name); err != nil {
+			return removed, fmt.Errorf("Error deleting snapshot '%s': %v", snap.Name, err)
+		}
+		removed = append(removed, snap)
+	}
+
+	return
+}
@@ -0,0 +1,117 @@
+package proxmox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// CloudInitUserData - the common user-data settings, to render with
+// RenderUserData. Any value encoding to a JSON object can be rendered too,
+// for the modules not covered here
+type CloudInitUserData struct {
+	Hostname          string               `json:"hostname,omitempty"`
+	FQDN              string               `json:"fqdn,omitempty"`
+	ManageEtcHosts    bool                 `json:"manage_etc_hosts,omitempty"`
+	Timezone          string               `json:"timezone,omitempty"`
+	Users             []CloudInitUser      `json:"users,omitempty"`
+	SSHAuthorizedKeys []string             `json:"ssh_authorized_keys,omitempty"`
+	SSHPwauth         *bool                `json:"ssh_pwauth,omitempty"`
+	PackageUpdate     bool                 `json:"package_update,omitempty"`
+	PackageUpgrade    bool                 `json:"package_upgrade,omitempty"`
+	Packages          []string             `json:"packages,omitempty"`
+	WriteFiles        []CloudInitFile      `json:"write_files,omitempty"`
+	Bootcmd           []interface{}        `json:"bootcmd,omitempty"`
+	Runcmd            []interface{}        `json:"runcmd,omitempty"`
+	PowerState        *CloudInitPowerState `json:"power_state,omitempty"`
+}
+
+// CloudInitUser - an entry of the users module, the string "default" can
+// be put in a raw users list for the image's default user instead
+type CloudInitUser struct {
+	Name              string   `json:"name"`
+	Gecos             string   `json:"gecos,omitempty"`
+	Groups            []string `json:"groups,omitempty"`
+	Shell             string   `json:"shell,omitempty"`
+	Sudo              string   `json:"sudo,omitempty"`
+	LockPasswd        *bool    `json:"lock_passwd,omitempty"`
+	Passwd            string   `json:"passwd,omitempty"`
+	SSHAuthorizedKeys []string `json:"ssh_authorized_keys,omitempty"`
+}
+
+// CloudInitFile - an entry of the write_files module
+type CloudInitFile struct {
+	Path        string `json:"path"`
+	Content     string `json:"content"`
+	Encoding    string `json:"encoding,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+	Append      bool   `json:"append,omitempty"`
+}
+
+// CloudInitPowerState - the power_state module, Mode is poweroff, reboot
+// or halt
+type CloudInitPowerState struct {
+	Mode    string `json:"mode"`
+	Delay   string `json:"delay,omitempty"`
+	Message string `json:"message,omitempty"`
+	Timeout int    `json:"timeout,omitempty"`
+}
+
+// CloudInitNetworkConfig - a version 2 (netplan style) network-config
+type CloudInitNetworkConfig struct {
+	Version   int                          `json:"version"`
+	Ethernets map[string]CloudInitEthernet `json:"ethernets,omitempty"`
+}
+
+// CloudInitEthernet - an interface of the network-config, matched by its
+// name (the ethernets key) or by Match
+type CloudInitEthernet struct {
+	Match       *CloudInitMatch       `json:"match,omitempty"`
+	SetName     string                `json:"set-name,omitempty"`
+	DHCP4       bool                  `json:"dhcp4,omitempty"`
+	DHCP6       bool                  `json:"dhcp6,omitempty"`
+	Addresses   []string              `json:"addresses,omitempty"`
+	Gateway4    string                `json:"gateway4,omitempty"`
+	Gateway6    string                `json:"gateway6,omitempty"`
+	MTU         int                   `json:"mtu,omitempty"`
+	Nameservers *CloudInitNameservers `json:"nameservers,omitempty"`
+}
+
+type CloudInitMatch struct {
+	MACAddress string `json:"macaddress,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+type CloudInitNameservers struct {
+	Addresses []string `json:"addresses,omitempty"`
+	Search    []string `json:"search,omitempty"`
+}
+
+// RenderUserData - user-data from v, a #cloud-config document
+// The document body is JSON, which is valid YAML and avoids depending on
+// a YAML encoder. PVE can't upload snippets through its API, so the caller
+// places the document in the snippets directory of a storage with the
+// snippets content type, and references its volume (as in
+// "local:snippets/user-data.yaml") from ConfigQemu.Cicustom
+func RenderUserData(v interface{}) ([]byte, error) {
+	return renderCloudConfig("#cloud-config\n", v)
+}
+
+// RenderNetworkConfig - network-config from v, usually a
+// CloudInitNetworkConfig
+func RenderNetworkConfig(v interface{}) ([]byte, error) {
+	return renderCloudConfig("", v)
+}
+
+func renderCloudConfig(header string, v interface{}) ([]byte, error) {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Error rendering cloud-init data: %v", err)
+	}
+	if !bytes.HasPrefix(body, []byte("{")) {
+		return nil, fmt.Errorf("Cloud-init data must be an object, not %s", body)
+	}
+
+	return append(append([]byte(header), body...), '\n'), nil
+}
@@ -0,0 +1,225 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StartupOrder - when a guest is started or stopped along the rest of the
+// node guests, PVE's `order=N,up=S,down=S` startup option
+// Guests start by ascending Order and stop in reverse, Up is the delay in
+// seconds before starting the next guest and Down the timeout to shut it
+// down. Order is not set when nil (order 0 is a valid order), Up and Down
+// when 0. Guests without order start after the rest
+type StartupOrder struct {
+	Order *int `json:"order,omitempty"`
+	Up    int  `json:"up,omitempty"`
+	Down  int  `json:"down,omitempty"`
+}
+
+// ParseStartupOrder - parse the PVE startup syntax, "[[order=]N][,up=N][,down=N]"
+func ParseStartupOrder(startup string) (so StartupOrder, err error) {
+	return parseStartupOrder(startup, true)
+}
+
+// readStartupOrder - the startup option of an API config, the items it
+// doesn't know are skipped so reading the config doesn't fail on them
+func readStartupOrder(startup string) (so StartupOrder) {
+	so, _ = parseStartupOrder(startup, false)
+	return
+}
+
+func parseStartupOrder(startup string, strict bool) (so StartupOrder, err error) {
+	if startup == "" {
+		return
+	}
+
+	for i, item := range strings.Split(startup, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		// the order can go first without its key
+		if len(kv) == 1 && i == 0 {
+			kv = []string{"order", item}
+		}
+
+		var value int
+		if len(kv) == 2 {
+			value, err = strconv.Atoi(kv[1])
+		}
+		if len(kv) != 2 || err != nil || value < 0 {
+			if !strict {
+				err = nil
+				continue
+			}
+			return so, fmt.Errorf("Invalid startup option '%s'", item)
+		}
+
+		switch kv[0] {
+		case "order":
+			order := value
+			so.Order = &order
+		case "up":
+			so.Up = value
+		case "down":
+			so.Down = value
+		default:
+			if strict {
+				return so, fmt.Errorf("Invalid startup option '%s'", kv[0])
+			}
+		}
+	}
+
+	return
+}
+
+func (so StartupOrder) IsSet() bool {
+	return so.Order != nil || so.Up > 0 || so.Down > 0
+}
+
+// String - the PVE startup option syntax
+func (so StartupOrder) String() string {
+	items := []string{}
+	if so.Order != nil {
+		items = append(items, fmt.Sprintf("order=%d", *so.Order))
+	}
+	if so.Up > 0 {
+		items = append(items, fmt.Sprintf("up=%d", so.Up))
+	}
+	if so.Down > 0 {
+		items = append(items, fmt.Sprintf("down=%d", so.Down))
+	}
+
+	return strings.Join(items, ",")
+}
+
+// the JSON form is the PVE syntax, an object with the fields is accepted too
+func (so StartupOrder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(so.String())
+}
+
+func (so *StartupOrder) UnmarshalJSON(b []byte) (err error) {
+	var startup string
+	if err = json.Unmarshal(b, &startup); err == nil {
+		*so, err = ParseStartupOrder(startup)
+		return
+	}
+
+	type fields StartupOrder
+	return json.Unmarshal(b, (*fields)(so))
+}
+
+// GetStartupOrder - the startup option of the guest config
+func (vm *Vm) GetStartupOrder() (so StartupOrder, err error) {
+	var vmConfig map[string]interface{}
+	if vmConfig, err = vm.GetConfig(); err != nil {
+		return
+	}
+
+	startup, _ := vmConfig["startup"].(string)
+	return readStartupOrder(startup), nil
+}
+
+// nodeGuests - the guests of the node with their startup order, by vmid
+func (node *Node) nodeGuests(status string, onbootOnly bool) (vms []*Vm, orders map[*Vm]StartupOrder, err error) {
+	var candidates []*Vm
+	if candidates, err = FindVms(BulkFilter{Node: node.name, Status: status}); err != nil {
+		return
+	}
+
+	orders = map[*Vm]StartupOrder{}
+	for _, vm := range candidates {
+		var vmConfig map[string]interface{}
+		if vmConfig, err = vm.GetConfig(); err != nil {
+			return
+		}
+		if onbootOnly && fmt.Sprint(vmConfig["onboot"]) != "1" {
+			continue
+		}
+
+		startup, _ := vmConfig["startup"].(string)
+		orders[vm] = readStartupOrder(startup)
+		vms = append(vms, vm)
+	}
+
+	return
+}
+
+// startupSorted - ascending order, guests without order last
+func startupSorted(vms []*Vm, orders map[*Vm]StartupOrder) []*Vm {
+	sorted := append([]*Vm{}, vms...)
+	rank := func(vm *Vm) int {
+		if order := orders[vm].Order; order != nil {
+			return *order
+		}
+		return int(^uint(0) >> 1)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return rank(sorted[i]) < rank(sorted[j]) })
+
+	return sorted
+}
+
+// StartAll - start the stopped guests of the node one at a time in startup
+// order, waiting the Up delay of each before the next, as PVE's startall
+// With onbootOnly only the guests set to start on boot are started
+func (node *Node) StartAll(onbootOnly bool) (report []BulkResult, err error) {
+	var (
+		vms    []*Vm
+		orders map[*Vm]StartupOrder
+	)
+	if vms, orders, err = node.nodeGuests("stopped", onbootOnly); err != nil {
+		return
+	}
+
+	for _, vm := range startupSorted(vms, orders) {
+		result := BulkResult{Vmid: vm.id, Node: node.name, Started: time.Now()}
+		result.ExitStatus, result.Err = vm.Start()
+		result.Finished = time.Now()
+		if result.Err != nil {
+			result.Error = result.Err.Error()
+		} else if up := orders[vm].Up; up > 0 {
+			time.Sleep(time.Duration(up) * time.Second)
+		}
+		report = append(report, result)
+	}
+
+	return
+}
+
+// defaultStopTimeout - the shutdown timeout for guests without Down, as PVE
+const defaultStopTimeout = 180
+
+// StopAll - shut down the running guests of the node one at a time in
+// reverse startup order, as PVE's stopall. Guests not shut down within their
+// Down timeout are stopped
+func (node *Node) StopAll() (report []BulkResult, err error) {
+	var (
+		vms    []*Vm
+		orders map[*Vm]StartupOrder
+	)
+	if vms, orders, err = node.nodeGuests("running", false); err != nil {
+		return
+	}
+
+	sorted := startupSorted(vms, orders)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		vm := sorted[i]
+
+		timeout := orders[vm].Down
+		if timeout <= 0 {
+			timeout = defaultStopTimeout
+		}
+
+		result := BulkResult{Vmid: vm.id, Node: node.name, Started: time.Now()}
+		result.ExitStatus, result.Err = vm.ShutdownWithTimeout(timeout, true)
+		result.Finished = time.Now()
+		if result.Err != nil {
+			result.Error = result.Err.Error()
+		}
+		report = append(report, result)
+	}
+
+	return
+}
@@ -3,6 +3,9 @@ package proxmox
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
 )
 
 type Storage struct {
@@ -53,3 +56,45 @@ func (storage *Storage) GetInfo() (storageInfo map[string]interface{}, err error
 	}
 	return nil, errors.New(fmt.Sprintf("Storage '%s' not found", storage.name))
 }
+
+// StorageVolume - an entry of the storage content list
+type StorageVolume struct {
+	Volid        string              `json:"volid"`
+	Content      string              `json:"content"`
+	Format       string              `json:"format"`
+	Size         int64               `json:"size"`
+	Used         int64               `json:"used,omitempty"`
+	Ctime        int64               `json:"ctime,omitempty"`
+	VMID         int                 `json:"vmid,omitempty"`
+	Notes        string              `json:"notes,omitempty"`
+	Protected    PVEBool             `json:"protected"`
+	Subtype      string              `json:"subtype,omitempty"`
+	Encrypted    string              `json:"encrypted,omitempty"`
+	Verification *VolumeVerification `json:"verification,omitempty"`
+}
+
+// VolumeVerification - verification state of a backup, as reported by PBS
+type VolumeVerification struct {
+	State string `json:"state"`
+	Upid  string `json:"upid"`
+}
+
+// GetContent - list the volumes in the storage as seen from node, content
+// and vmid are optional filters (pass "" and 0 to list everything)
+func (storage *Storage) GetContent(node *Node, content string, vmid int) (list []StorageVolume, err error) {
+	params := url.Values{}
+	if content != "" {
+		params.Set("content", content)
+	}
+	if vmid > 0 {
+		params.Set("vmid", strconv.Itoa(vmid))
+	}
+
+	var resp *http.Response
+	url := fmt.Sprintf("/nodes/%s/storage/%s/content", node.name, storage.name)
+	if resp, err = GetClient().session.Get(url, &params, nil); err == nil {
+		err = DataResponse(resp, &list)
+	}
+
+	return
+}
@@ -0,0 +1,139 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Task - handle to an asynchronous PVE task, identified by its UPID
+type Task struct {
+	upid string
+	node *Node
+}
+
+func NewTask(upid string) (task *Task, err error) {
+	match := rxTaskNode.FindStringSubmatch(upid)
+	if match == nil {
+		return nil, fmt.Errorf("Invalid task UPID '%s'", upid)
+	}
+
+	return &Task{upid: upid, node: NewNode(match[1])}, nil
+}
+
+// NewTaskFromResponse - the task started by a request, from its decoded
+// response. The task is nil when the request completed synchronously
+func NewTaskFromResponse(taskResponse map[string]interface{}) (task *Task, err error) {
+	if taskResponse["errors"] != nil {
+		return nil, fmt.Errorf("Error reponse: %v", taskResponse["errors"])
+	}
+	if upid, isString := taskResponse["data"].(string); isString {
+		return NewTask(upid)
+	}
+
+	return
+}
+
+func (task *Task) Upid() string {
+	return task.upid
+}
+
+func (task *Task) Node() *Node {
+	return task.node
+}
+
+func (task *Task) GetStatus() (taskStatus map[string]interface{}, err error) {
+	url := fmt.Sprintf("/nodes/%s/tasks/%s/status", task.node.name, task.upid)
+	var resp map[string]interface{}
+	if _, err = GetClient().session.GetJSON(url, nil, nil, &resp); err == nil {
+		if resp["data"] == nil {
+			return nil, errors.New("Task status could not be read")
+		}
+		taskStatus = resp["data"].(map[string]interface{})
+	}
+
+	return
+}
+
+// GetExitStatus - nil while the task is running, see GetTaskExitstatus
+func (task *Task) GetExitStatus() (exitStatus interface{}, err error) {
+	return GetClient().GetTaskExitstatus(task.upid)
+}
+
+// Wait - poll the API for task completion, a nil task (from a request that
+// completed synchronously) returns right away
+func (task *Task) Wait() (exitStatus string, err error) {
+	if task == nil {
+		return "", nil
+	}
+
+	waited := 0
+	for waited < TaskTimeout {
+		status, statErr := task.GetExitStatus()
+		if statErr != nil {
+			if statErr != io.ErrUnexpectedEOF { // don't give up on ErrUnexpectedEOF
+				return "", statErr
+			}
+		}
+		if status != nil {
+			return status.(string), nil
+		}
+		time.Sleep(TaskStatusCheckInterval * time.Second)
+		waited = waited + TaskStatusCheckInterval
+	}
+
+	return "", errors.New("Wait timeout for:" + task.upid)
+}
+
+// Stop - abort a running task
+func (task *Task) Stop() (err error) {
+	url := fmt.Sprintf("/nodes/%s/tasks/%s", task.node.name, task.upid)
+	_, err = GetClient().session.Delete(url, nil, nil)
+
+	return
+}
+
+// startTask - send a request to an endpoint that starts a task and return
+// the task handle without waiting for it
+func startTask(method string, url string, params map[string]interface{}) (task *Task, err error) {
+	var reqbody *[]byte
+	if params != nil {
+		body := ParamsToBody(params)
+		reqbody = &body
+	}
+
+	var resp *http.Response
+	switch method {
+	case "POST":
+		resp, err = GetClient().session.Post(url, nil, nil, reqbody)
+	case "PUT":
+		resp, err = GetClient().session.Put(url, nil, nil, reqbody)
+	case "DELETE":
+		resp, err = GetClient().session.Delete(url, nil, nil)
+	default:
+		err = fmt.Errorf("Unsupported task request method '%s'", method)
+	}
+
+	if err != nil {
+		return
+	}
+
+	var taskResponse map[string]interface{}
+	if taskResponse, err = ResponseJSON(resp); err == nil {
+		task, err = NewTaskFromResponse(taskResponse)
+	}
+
+	return
+}
+
+// waitTask - wait for a task just started, for the blocking counterparts of
+// the *Async methods
+func waitTask(task *Task, err error) (exitStatus string, waitErr error) {
+	if err != nil {
+		return "", err
+	}
+
+	return task.Wait()
+}
@@ -0,0 +1,287 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EFIDisk - the disk storing the OVMF EFI vars, PVE's efidisk0 option
+// An EFIDisk without File is allocated in Storage when the VM is created,
+// with pre-enrolled Secure Boot keys when PreEnrolledKeys is set. Efitype
+// is 2m or 4m (needed for Secure Boot), MsCert the Microsoft certificate
+// version of the keys and Size is only informative. Options has the options
+// without a field, which are kept as they are
+type EFIDisk struct {
+	Storage         string            `json:"storage,omitempty"`
+	File            string            `json:"file,omitempty"`
+	Format          string            `json:"format,omitempty"`
+	Efitype         string            `json:"efitype,omitempty"`
+	PreEnrolledKeys bool              `json:"pre-enrolled-keys,omitempty"`
+	MsCert          string            `json:"ms-cert,omitempty"`
+	Size            string            `json:"size,omitempty"`
+	Options         map[string]string `json:"options,omitempty"`
+}
+
+// ParseEFIDisk - parse the PVE efidisk0 syntax, not validated as it's also
+// used for the API values
+func ParseEFIDisk(efidisk string) (disk EFIDisk, err error) {
+	var options map[string]string
+	if disk.Storage, disk.File, options, err = parseStateDisk("efidisk", efidisk); err != nil {
+		return
+	}
+
+	for key, value := range options {
+		switch key {
+		case "format":
+			disk.Format = value
+		case "efitype":
+			disk.Efitype = value
+		case "pre-enrolled-keys":
+			disk.PreEnrolledKeys = value == "1"
+		case "ms-cert":
+			disk.MsCert = value
+		case "size":
+			disk.Size = value
+		default:
+			if disk.Options == nil {
+				disk.Options = map[string]string{}
+			}
+			disk.Options[key] = value
+		}
+	}
+
+	return
+}
+
+func (disk EFIDisk) IsSet() bool {
+	return disk.Storage != ""
+}
+
+func (disk EFIDisk) Validate() error {
+	if !disk.IsSet() {
+		if disk.File != "" || disk.Format != "" || disk.Efitype != "" || disk.PreEnrolledKeys ||
+			disk.MsCert != "" || disk.Size != "" || len(disk.Options) > 0 {
+			return fmt.Errorf("The efidisk needs a storage")
+		}
+		return nil
+	}
+	if disk.Efitype != "" && disk.Efitype != "2m" && disk.Efitype != "4m" {
+		return fmt.Errorf("Invalid efitype '%s', must be 2m or 4m", disk.Efitype)
+	}
+	if disk.PreEnrolledKeys && disk.File == "" && disk.Efitype != "4m" {
+		return fmt.Errorf("Pre-enrolled keys need efitype 4m")
+	}
+
+	return nil
+}
+
+// String - the PVE efidisk0 option syntax, allocating the disk when there
+// is no File
+func (disk EFIDisk) String() string {
+	if !disk.IsSet() {
+		return ""
+	}
+
+	items := []string{stateDiskVolume(disk.Storage, disk.File)}
+	if disk.Format != "" {
+		items = append(items, "format="+disk.Format)
+	}
+	if disk.Efitype != "" {
+		items = append(items, "efitype="+disk.Efitype)
+	}
+	if disk.PreEnrolledKeys {
+		items = append(items, "pre-enrolled-keys=1")
+	}
+	if disk.MsCert != "" {
+		items = append(items, "ms-cert="+disk.MsCert)
+	}
+	if disk.Size != "" && disk.File != "" {
+		items = append(items, "size="+disk.Size)
+	}
+	items = append(items, optionItems(disk.Options)...)
+
+	return strings.Join(items, ",")
+}
+
+// the JSON form is the PVE syntax, an object with the fields is accepted too
+func (disk EFIDisk) MarshalJSON() ([]byte, error) {
+	return json.Marshal(disk.String())
+}
+
+func (disk *EFIDisk) UnmarshalJSON(b []byte) (err error) {
+	var efidisk string
+	if err = json.Unmarshal(b, &efidisk); err == nil {
+		if *disk, err = ParseEFIDisk(efidisk); err == nil {
+			err = disk.Validate()
+		}
+		return
+	}
+
+	type fields EFIDisk
+	if err = json.Unmarshal(b, (*fields)(disk)); err != nil {
+		return
+	}
+	return disk.Validate()
+}
+
+// TPMState - the disk storing the TPM state, PVE's tpmstate0 option
+// A TPMState without File is allocated in Storage when the VM is created.
+// Version is v1.2 or v2.0 (needed by Windows 11), Size is only informative.
+// Options has the options without a field, which are kept as they are
+type TPMState struct {
+	Storage string            `json:"storage,omitempty"`
+	File    string            `json:"file,omitempty"`
+	Version string            `json:"version,omitempty"`
+	Size    string            `json:"size,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// ParseTPMState - parse the PVE tpmstate0 syntax, not validated as it's also
+// used for the API values
+func ParseTPMState(tpmstate string) (state TPMState, err error) {
+	var options map[string]string
+	if state.Storage, state.File, options, err = parseStateDisk("tpmstate", tpmstate); err != nil {
+		return
+	}
+
+	for key, value := range options {
+		switch key {
+		case "version":
+			state.Version = value
+		case "size":
+			state.Size = value
+		default:
+			if state.Options == nil {
+				state.Options = map[string]string{}
+			}
+			state.Options[key] = value
+		}
+	}
+
+	return
+}
+
+func (state TPMState) IsSet() bool {
+	return state.Storage != ""
+}
+
+func (state TPMState) Validate() error {
+	if !state.IsSet() {
+		if state.File != "" || state.Version != "" || state.Size != "" || len(state.Options) > 0 {
+			return fmt.Errorf("The tpmstate needs a storage")
+		}
+		return nil
+	}
+	if state.Version != "" && state.Version != "v1.2" && state.Version != "v2.0" {
+		return fmt.Errorf("Invalid TPM version '%s', must be v1.2 or v2.0", state.Version)
+	}
+
+	return nil
+}
+
+// String - the PVE tpmstate0 option syntax, allocating the disk when there
+// is no File
+func (state TPMState) String() string {
+	if !state.IsSet() {
+		return ""
+	}
+
+	items := []string{stateDiskVolume(state.Storage, state.File)}
+	if state.Version != "" {
+		items = append(items, "version="+state.Version)
+	}
+	if state.Size != "" && state.File != "" {
+		items = append(items, "size="+state.Size)
+	}
+	items = append(items, optionItems(state.Options)...)
+
+	return strings.Join(items, ",")
+}
+
+// the JSON form is the PVE syntax, an object with the fields is accepted too
+func (state TPMState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(state.String())
+}
+
+func (state *TPMState) UnmarshalJSON(b []byte) (err error) {
+	var tpmstate string
+	if err = json.Unmarshal(b, &tpmstate); err == nil {
+		if *state, err = ParseTPMState(tpmstate); err == nil {
+			err = state.Validate()
+		}
+		return
+	}
+
+	type fields TPMState
+	if err = json.Unmarshal(b, (*fields)(state)); err != nil {
+		return
+	}
+	return state.Validate()
+}
+
+// parseStateDisk - split a "storage:file,key=value,..." disk option, the
+// file of a disk to allocate is its size in GB, which is dropped
+func parseStateDisk(name, option string) (storage, file string, options map[string]string, err error) {
+	options = map[string]string{}
+	if option == "" {
+		return
+	}
+
+	items := strings.Split(option, ",")
+	volume := strings.SplitN(strings.TrimPrefix(items[0], "file="), ":", 2)
+	if len(volume) != 2 || volume[0] == "" {
+		return "", "", nil, fmt.Errorf("Invalid %s volume '%s'", name, items[0])
+	}
+	storage, file = volume[0], volume[1]
+	if file == "1" || file == "0" {
+		file = ""
+	}
+
+	for _, item := range items[1:] {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return "", "", nil, fmt.Errorf("Invalid %s option '%s'", name, item)
+		}
+		options[kv[0]] = kv[1]
+	}
+
+	return
+}
+
+// optionItems - the options as "key=value" items, sorted by key
+func optionItems(options map[string]string) (items []string) {
+	for key, value := range options {
+		items = append(items, key+"="+value)
+	}
+	sort.Strings(items)
+
+	return
+}
+
+// stateDiskVolume - the volume of an existing disk, or the size to
+// allocate one (the size of these disks is fixed by PVE)
+func stateDiskVolume(storage, file string) string {
+	if file == "" {
+		file = "1"
+	}
+	return storage + ":" + file
+}
+
+// allocatesStateDisks - the efidisk and tpmstate params allocating a disk
+// that vmConfig already has, which would replace the current one and its
+// state
+func allocatesStateDisks(params map[string]interface{}, vmConfig map[string]interface{}) (keys []string) {
+	for _, key := range []string{"efidisk0", "tpmstate0"} {
+		param, isSet := params[key].(string)
+		if _, hasDisk := vmConfig[key]; !isSet || !hasDisk {
+			continue
+		}
+		if storage, file, _, err := parseStateDisk(key, param); err == nil && storage != "" && file == "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return
+}
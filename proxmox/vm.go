@@ -1,13 +1,11 @@
 package proxmox
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/3coma3/proxmox-api-go/keyboard"
 	"io/ioutil"
 	"log"
-	"net"
-	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -152,6 +150,18 @@ func GetNextVmId(currentId int) (nextId int, err error) {
 }
 
 func (vm *Vm) Create(vmParams map[string]interface{}) (exitStatus string, err error) {
+	checks := []privilegeCheck{vmPrivilegeCheck(vm.id, "VM.Allocate")}
+	if param, isSet := vmParams["pool"]; isSet {
+		pool, isString := param.(string)
+		if !isString {
+			return "", fmt.Errorf("Invalid pool parameter '%v', must be a string", param)
+		}
+		checks = append(checks, poolPrivilegeCheck(pool, "VM.Allocate"))
+	}
+	if err = GetClient().preflight(checks...); err != nil {
+		return
+	}
+
 	// Create VM disks first to ensure disks names.
 	createdDisks, createdDisksErr := vm.createDisks(vmParams)
 	if createdDisksErr != nil {
@@ -190,48 +200,110 @@ func (vm *Vm) Create(vmParams map[string]interface{}) (exitStatus string, err er
 	return
 }
 
-func (vm *Vm) CreateTemplate() (exitStatus interface{}, err error) {
+func (vm *Vm) CreateTemplate() (exitStatus string, err error) {
+	return waitTask(vm.CreateTemplateAsync())
+}
+
+func (vm *Vm) CreateTemplateAsync() (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
 	url := fmt.Sprintf("/nodes/%s/%s/%d/template", vm.node.name, vm.vmtype, vm.id)
-	return GetClient().session.Post(url, nil, nil, nil)
+	return startTask("POST", url, nil)
 }
 
-func (vm *Vm) Clone(newid int, cloneParams map[string]interface{}) (exitStatus interface{}, err error) {
+// Clone - clone the guest and wait for it. Clones to another node of guests
+// on non-shared storage are done as a clone on the source node followed by an
+// offline migration, see cloneAndMigrate
+func (vm *Vm) Clone(newid int, opts CloneOptions) (exitStatus string, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	if newid > 0 {
-		cloneParams["newid"] = newid
+	var vmConfig map[string]interface{}
+	if vmConfig, err = opts.sourceConfig(vm); err != nil {
+		return
+	}
+	if err = opts.validate(vm, vmConfig); err != nil {
+		return
 	}
 
-	reqbody := ParamsToBody(cloneParams)
+	var migrate bool
+	if migrate, err = opts.needsMigration(vm, vmConfig); err != nil {
+		return
+	}
+	if migrate {
+		return vm.cloneAndMigrate(newid, opts)
+	}
 
-	url := fmt.Sprintf("/nodes/%s/%s/%d/clone", vm.node.name, vm.vmtype, vm.id)
-	if resp, err := GetClient().session.Post(url, nil, nil, &reqbody); err == nil {
-		if taskResponse, err := ResponseJSON(resp); err == nil {
-			return GetClient().WaitForCompletion(taskResponse)
-		}
+	return waitTask(vm.cloneAsync(newid, opts))
+}
+
+func (vm *Vm) CloneAsync(newid int, opts CloneOptions) (task *Task, err error) {
+	if err = vm.Check(); err != nil {
+		return
 	}
 
-	return
+	var vmConfig map[string]interface{}
+	if vmConfig, err = opts.sourceConfig(vm); err != nil {
+		return
+	}
+	if err = opts.validate(vm, vmConfig); err != nil {
+		return
+	}
+
+	var migrate bool
+	if migrate, err = opts.needsMigration(vm, vmConfig); err != nil {
+		return
+	}
+	if migrate {
+		return nil, fmt.Errorf("Cloning to node '%s' needs a migration after the clone, use Clone", opts.Target)
+	}
+
+	return vm.cloneAsync(newid, opts)
+}
+
+// cloneAsync - start the clone task, once opts are validated and the clone
+// is known not to need a migration
+func (vm *Vm) cloneAsync(newid int, opts CloneOptions) (task *Task, err error) {
+	checks := []privilegeCheck{vmPrivilegeCheck(vm.id, "VM.Clone")}
+	if opts.Pool != "" {
+		checks = append(checks, poolPrivilegeCheck(opts.Pool, "VM.Allocate"))
+	} else if newid > 0 {
+		checks = append(checks, vmPrivilegeCheck(newid, "VM.Allocate"))
+	}
+	if opts.Storage != "" {
+		checks = append(checks, storagePrivilegeCheck(opts.Storage, "Datastore.AllocateSpace"))
+	}
+	if err = GetClient().preflight(checks...); err != nil {
+		return
+	}
+
+	cloneParams := opts.Params(vm.vmtype)
+	if newid > 0 {
+		cloneParams["newid"] = newid
+	}
+
+	url := fmt.Sprintf("/nodes/%s/%s/%d/clone", vm.node.name, vm.vmtype, vm.id)
+	return startTask("POST", url, cloneParams)
 }
 
 func (vm *Vm) Delete() (exitStatus string, err error) {
+	return waitTask(vm.DeleteAsync())
+}
+
+func (vm *Vm) DeleteAsync() (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	url := fmt.Sprintf("/nodes/%s/%s/%d", vm.node.name, vm.vmtype, vm.id)
-	var taskResponse map[string]interface{}
-	if _, err = GetClient().session.RequestJSON("DELETE", url, nil, nil, nil, &taskResponse); err == nil {
-		return GetClient().WaitForCompletion(taskResponse)
+	if err = GetClient().preflight(vmPrivilegeCheck(vm.id, "VM.Allocate")); err != nil {
+		return
 	}
 
-	return
+	url := fmt.Sprintf("/nodes/%s/%s/%d", vm.node.name, vm.vmtype, vm.id)
+	return startTask("DELETE", url, nil)
 }
 
 func (vm *Vm) GetConfig() (config map[string]interface{}, err error) {
@@ -251,28 +323,23 @@ func (vm *Vm) GetConfig() (config map[string]interface{}, err error) {
 	return
 }
 
-func (vm *Vm) SetConfig(vmParams map[string]interface{}) (exitStatus interface{}, err error) {
+func (vm *Vm) SetConfig(vmParams map[string]interface{}) (exitStatus string, err error) {
+	return waitTask(vm.SetConfigAsync(vmParams))
+}
+
+// SetConfigAsync - CTs are updated synchronously, so their task is nil
+func (vm *Vm) SetConfigAsync(vmParams map[string]interface{}) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	reqbody := ParamsToBody(vmParams)
 	url := fmt.Sprintf("/nodes/%s/%s/%d/config", vm.node.name, vm.vmtype, vm.id)
-	var resp *http.Response
 
 	// Use the POST async API to update qemu VMs, PUT for CTs
 	if vm.vmtype == "qemu" {
-		resp, err = GetClient().session.Post(url, nil, nil, &reqbody)
-	} else {
-		resp, err = GetClient().session.Put(url, nil, nil, &reqbody)
-	}
-
-	if err == nil {
-		if taskResponse, err := ResponseJSON(resp); err == nil {
-			exitStatus, err = GetClient().WaitForCompletion(taskResponse)
-		}
+		return startTask("POST", url, vmParams)
 	}
-	return
+	return startTask("PUT", url, vmParams)
 }
 
 func (vm *Vm) GetStatus() (vmState map[string]interface{}, err error) {
@@ -293,15 +360,8 @@ func (vm *Vm) GetStatus() (vmState map[string]interface{}, err error) {
 }
 
 func (vm *Vm) SetStatus(status string) (exitStatus string, err error) {
-	if err = vm.Check(); err != nil {
-		return
-	}
-
-	url := fmt.Sprintf("/nodes/%s/%s/%d/status/%s", vm.node.name, vm.vmtype, vm.id, status)
-	var taskResponse map[string]interface{}
 	for i := 0; i < 3; i++ {
-		_, err = GetClient().session.PostJSON(url, nil, nil, nil, &taskResponse)
-		exitStatus, err = GetClient().WaitForCompletion(taskResponse)
+		exitStatus, err = waitTask(vm.SetStatusAsync(status))
 		if exitStatus == "" {
 			time.Sleep(TaskStatusCheckInterval * time.Second)
 		} else {
@@ -312,6 +372,19 @@ func (vm *Vm) SetStatus(status string) (exitStatus string, err error) {
 	return
 }
 
+func (vm *Vm) SetStatusAsync(status string) (task *Task, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	if err = GetClient().preflight(vmPrivilegeCheck(vm.id, "VM.PowerMgmt")); err != nil {
+		return
+	}
+
+	url := fmt.Sprintf("/nodes/%s/%s/%d/status/%s", vm.node.name, vm.vmtype, vm.id, status)
+	return startTask("POST", url, nil)
+}
+
 func (vm *Vm) Start() (exitStatus string, err error) {
 	return vm.SetStatus("start")
 }
@@ -336,6 +409,26 @@ func (vm *Vm) Shutdown() (exitStatus string, err error) {
 	return vm.SetStatus("shutdown")
 }
 
+// ShutdownWithTimeout - shut down waiting up to timeout seconds, with
+// forceStop the guest is stopped if it didn't shut down by then
+func (vm *Vm) ShutdownWithTimeout(timeout int, forceStop bool) (exitStatus string, err error) {
+	if err = vm.Check(); err != nil {
+		return
+	}
+
+	if err = GetClient().preflight(vmPrivilegeCheck(vm.id, "VM.PowerMgmt")); err != nil {
+		return
+	}
+
+	params := map[string]interface{}{"timeout": timeout}
+	if forceStop {
+		params["forceStop"] = true
+	}
+
+	url := fmt.Sprintf("/nodes/%s/%s/%d/status/shutdown", vm.node.name, vm.vmtype, vm.id)
+	return waitTask(startTask("POST", url, params))
+}
+
 // Useful waiting for ISO install to complete
 func (vm *Vm) WaitForShutdown() (err error) {
 	if err = vm.Check(); err != nil {
@@ -355,20 +448,31 @@ func (vm *Vm) WaitForShutdown() (err error) {
 	return errors.New("Not shutdown within wait time")
 }
 
-func (vm *Vm) Migrate(migrateParams map[string]interface{}) (exitStatus interface{}, err error) {
+func (vm *Vm) Migrate(opts MigrateOptions) (exitStatus string, err error) {
+	return waitTask(vm.MigrateAsync(opts))
+}
+
+// MigrateAsync - the migration is checked against the PVE preconditions
+// before starting the task, see checkMigration
+func (vm *Vm) MigrateAsync(opts MigrateOptions) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	reqbody := ParamsToBody(migrateParams)
-	url := fmt.Sprintf("/nodes/%s/%s/%d/migrate", vm.node.name, vm.vmtype, vm.id)
-	if resp, err := GetClient().session.Post(url, nil, nil, &reqbody); err == nil {
-		if taskResponse, err := ResponseJSON(resp); err == nil {
-			exitStatus, err = GetClient().WaitForCompletion(taskResponse)
-		}
+	if err = opts.Validate(vm.vmtype); err != nil {
+		return
 	}
 
-	return
+	if err = GetClient().preflight(vmPrivilegeCheck(vm.id, "VM.Migrate")); err != nil {
+		return
+	}
+
+	if err = vm.checkMigration(opts); err != nil {
+		return
+	}
+
+	url := fmt.Sprintf("/nodes/%s/%s/%d/migrate", vm.node.name, vm.vmtype, vm.id)
+	return startTask("POST", url, opts.Params(vm.vmtype))
 }
 
 func (vm *Vm) GetSnapshotList() (list map[string]interface{}, err error) {
@@ -382,66 +486,89 @@ func (vm *Vm) GetSnapshotList() (list map[string]interface{}, err error) {
 	return
 }
 
-func (vm *Vm) CreateSnapshot(snapParams map[string]interface{}) (exitStatus string, err error) {
+func (vm *Vm) CreateSnapshot(opts SnapshotOptions) (exitStatus string, err error) {
+	return waitTask(vm.CreateSnapshotAsync(opts))
+}
+
+func (vm *Vm) CreateSnapshotAsync(opts SnapshotOptions) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	reqbody := ParamsToBody(snapParams)
-	url := fmt.Sprintf("/nodes/%s/%s/%d/snapshot", vm.node.name, vm.vmtype, vm.id)
-
-	var (
-		resp         *http.Response
-		taskResponse map[string]interface{}
-	)
+	if opts.Name == "" {
+		return nil, errors.New("Snapshot name is required")
+	}
+	if opts.Vmstate && vm.vmtype != "qemu" {
+		return nil, errors.New("Only VMs can save their state in snapshots")
+	}
 
-	if resp, err = GetClient().session.Post(url, nil, nil, &reqbody); err == nil {
-		if taskResponse, err = ResponseJSON(resp); err == nil {
-			exitStatus, err = GetClient().WaitForCompletion(taskResponse)
-		}
+	if err = GetClient().preflight(vmPrivilegeCheck(vm.id, "VM.Snapshot")); err != nil {
+		return
 	}
 
-	return
+	url := fmt.Sprintf("/nodes/%s/%s/%d/snapshot", vm.node.name, vm.vmtype, vm.id)
+	return startTask("POST", url, opts.Params())
+}
+
+func (vm *Vm) DeleteSnapshot(snapName string) (exitStatus string, err error) {
+	return waitTask(vm.DeleteSnapshotAsync(snapName))
 }
 
-func (vm *Vm) DeleteSnapshot(snapName string) (exitStatus interface{}, err error) {
+func (vm *Vm) DeleteSnapshotAsync(snapName string) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
+	if err = GetClient().preflight(vmPrivilegeCheck(vm.id, "VM.Snapshot")); err != nil {
+		return
+	}
+
 	url := fmt.Sprintf("/nodes/%s/%s/%d/snapshot/%s", vm.node.name, vm.vmtype, vm.id, snapName)
-	return GetClient().session.Delete(url, nil, nil)
+	return startTask("DELETE", url, nil)
 }
 
 func (vm *Vm) Rollback(snapName string) (exitStatus string, err error) {
+	return waitTask(vm.RollbackAsync(snapName))
+}
+
+func (vm *Vm) RollbackAsync(snapName string) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	url := fmt.Sprintf("/nodes/%s/%s/%d/snapshot/%s/rollback", vm.node.name, vm.vmtype, vm.id, snapName)
-	var taskResponse map[string]interface{}
-	if _, err = GetClient().session.PostJSON(url, nil, nil, nil, &taskResponse); err == nil {
-		exitStatus, err = GetClient().WaitForCompletion(taskResponse)
+	if err = GetClient().preflight(vmPrivilegeCheck(vm.id, "VM.Snapshot.Rollback")); err != nil {
+		return
 	}
 
-	return
+	url := fmt.Sprintf("/nodes/%s/%s/%d/snapshot/%s/rollback", vm.node.name, vm.vmtype, vm.id, snapName)
+	return startTask("POST", url, nil)
+}
+
+func (vm *Vm) CreateBackup(opts VzdumpOptions) (exitStatus string, err error) {
+	return waitTask(vm.CreateBackupAsync(opts))
 }
 
-func (vm *Vm) CreateBackup(bkpParams map[string]interface{}) (exitStatus string, err error) {
+func (vm *Vm) CreateBackupAsync(opts VzdumpOptions) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	bkpParams["vmid"] = vm.id
-	reqbody := ParamsToBody(bkpParams)
-	url := fmt.Sprintf("/nodes/%s/vzdump", vm.node.name)
-	if resp, err := GetClient().session.Post(url, nil, nil, &reqbody); err == nil {
-		if taskResponse, err := ResponseJSON(resp); err == nil {
-			exitStatus, err = GetClient().WaitForCompletion(taskResponse)
-		}
+	if err = opts.Validate(); err != nil {
+		return
 	}
 
-	return
+	checks := []privilegeCheck{vmPrivilegeCheck(vm.id, "VM.Backup")}
+	if opts.Storage != "" {
+		checks = append(checks, storagePrivilegeCheck(opts.Storage, "Datastore.AllocateSpace"))
+	}
+	if err = GetClient().preflight(checks...); err != nil {
+		return
+	}
+
+	bkpParams := opts.Params()
+	bkpParams["vmid"] = vm.id
+	url := fmt.Sprintf("/nodes/%s/vzdump", vm.node.name)
+	return startTask("POST", url, bkpParams)
 }
 
 // createDisks - Make disks parameters and create all VM disks on host node.
@@ -497,12 +624,15 @@ func (vm *Vm) createDisks(vmParams map[string]interface{}) (createdDisks []strin
 	return
 }
 
-func (vm *Vm) MoveDisk(moveParams map[string]interface{}) (exitStatus interface{}, err error) {
+func (vm *Vm) MoveDisk(moveParams map[string]interface{}) (exitStatus string, err error) {
+	return waitTask(vm.MoveDiskAsync(moveParams))
+}
+
+func (vm *Vm) MoveDiskAsync(moveParams map[string]interface{}) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	reqbody := ParamsToBody(moveParams)
 	url := fmt.Sprintf("/nodes/%s/%s/%d/move_", vm.node.name, vm.vmtype, vm.id)
 	if vm.vmtype == "qemu" {
 		url += "disk"
@@ -510,32 +640,23 @@ func (vm *Vm) MoveDisk(moveParams map[string]interface{}) (exitStatus interface{
 		url += "volume"
 	}
 
-	if resp, err := GetClient().session.Post(url, nil, nil, &reqbody); err == nil {
-		if taskResponse, err := ResponseJSON(resp); err == nil {
-			exitStatus, err = GetClient().WaitForCompletion(taskResponse)
-		}
-	}
-
-	return
+	return startTask("POST", url, moveParams)
 }
 
 // sizeGB can be a number to set an absolute size, or a number preceded by + to
 // grow the volume by that many GB. If using an absolute size this has to be
 // larger than the current size (shrinking is not supported by PVE)
-func (vm *Vm) ResizeDisk(disk string, sizeGB string) (exitStatus interface{}, err error) {
+func (vm *Vm) ResizeDisk(disk string, sizeGB string) (exitStatus string, err error) {
+	return waitTask(vm.ResizeDiskAsync(disk, sizeGB))
+}
+
+func (vm *Vm) ResizeDiskAsync(disk string, sizeGB string) (task *Task, err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	reqbody := ParamsToBody(map[string]interface{}{"disk": disk, "size": sizeGB})
 	url := fmt.Sprintf("/nodes/%s/%s/%d/resize", vm.node.name, vm.vmtype, vm.id)
-	if resp, err := GetClient().session.Put(url, nil, nil, &reqbody); err == nil {
-		if taskResponse, err := ResponseJSON(resp); err == nil {
-			exitStatus, err = GetClient().WaitForCompletion(taskResponse)
-		}
-	}
-
-	return
+	return startTask("PUT", url, map[string]interface{}{"disk": disk, "size": sizeGB})
 }
 
 // By default the VM disks are deteled when the VM is deleted,
@@ -582,72 +703,89 @@ func (vm *Vm) MonitorCmd(command string) (monitorRes map[string]interface{}, err
 	return
 }
 
+// SendKeysString - type text in the VM console with the US layout, see
+// keyboard.Parse for the syntax of special keys, chords and pauses
 func (vm *Vm) SendKeysString(keys string) (err error) {
+	return vm.SendKeysStringWithLayout(keys, keyboard.US, keyboard.DefaultDelay)
+}
+
+// SendKeysStringWithLayout - type text with layout, waiting delay between
+// the keystrokes as Packer's boot_command does
+func (vm *Vm) SendKeysStringWithLayout(keys string, layout keyboard.Layout, delay time.Duration) (err error) {
+	var keystrokes []keyboard.Keystroke
+	if keystrokes, err = keyboard.Parse(keys, layout); err == nil {
+		err = vm.SendKeystrokes(keystrokes, delay)
+	}
+
+	return
+}
+
+// KeystrokeError - a keystroke PVE failed to send, the ones before it were
+// sent and the ones after it were not
+type KeystrokeError struct {
+	Index     int
+	Keystroke keyboard.Keystroke
+	Err       error
+}
+
+func (e *KeystrokeError) Error() string {
+	return fmt.Sprintf("Error sending keystroke %d (%s): %v", e.Index, e.Keystroke, e.Err)
+}
+
+// SendKeystrokes - press the keystrokes in turn with the sendkey endpoint,
+// waiting delay between them (0 sends them back to back) and pausing where a
+// wait keystroke asks to. The endpoint takes a single key per request, and
+// concurrent requests could reach the guest out of order through different
+// PVE workers, so the batching is in preparing the requests: the guest state
+// is checked once and the request bodies are built before the first key is
+// sent, leaving one PUT per keystroke
+func (vm *Vm) SendKeystrokes(keystrokes []keyboard.Keystroke, delay time.Duration) (err error) {
 	if err = vm.Check(); err != nil {
 		return
 	}
 
-	if vmStatus, err := vm.GetStatus(); err == nil {
-		if vmStatus["status"] == "stopped" {
-			err = errors.New("VM must be running first")
-		} else {
-			for _, r := range keys {
-				c := string(r)
-				lower := strings.ToLower(c)
-				if c != lower {
-					c = "shift-" + lower
-				} else {
-					switch c {
-					case "!":
-						c = "shift-1"
-					case "@":
-						c = "shift-2"
-					case "#":
-						c = "shift-3"
-					case "$":
-						c = "shift-4"
-					case "%%":
-						c = "shift-5"
-					case "^":
-						c = "shift-6"
-					case "&":
-						c = "shift-7"
-					case "*":
-						c = "shift-8"
-					case "(":
-						c = "shift-9"
-					case ")":
-						c = "shift-0"
-					case "_":
-						c = "shift-minus"
-					case "+":
-						c = "shift-equal"
-					case " ":
-						c = "spc"
-					case "/":
-						c = "slash"
-					case "\\":
-						c = "backslash"
-					case ",":
-						c = "comma"
-					case "-":
-						c = "minus"
-					case "=":
-						c = "equal"
-					case ".":
-						c = "dot"
-					case "?":
-						c = "shift-slash"
-					}
-				}
+	if vm.vmtype != "qemu" {
+		return errors.New("Keys can only be sent to VMs")
+	}
 
-				if _, err = vm.MonitorCmd("sendkey " + c); err != nil {
-					break
-				}
+	var vmStatus map[string]interface{}
+	if vmStatus, err = vm.GetStatus(); err != nil {
+		return
+	}
+	if vmStatus["status"] == "stopped" {
+		return errors.New("VM must be running first")
+	}
+
+	reqbodies := make([][]byte, len(keystrokes))
+	for i, keystroke := range keystrokes {
+		if !keystroke.IsWait() {
+			reqbodies[i] = ParamsToBody(map[string]interface{}{"key": keystroke.String()})
+		}
+	}
+
+	session := GetClient().session
+	url := fmt.Sprintf("/nodes/%s/%s/%d/sendkey", vm.node.name, vm.vmtype, vm.id)
+	for i, keystroke := range keystrokes {
+		if keystroke.IsWait() {
+			time.Sleep(keystroke.Wait)
+			continue
+		}
+
+		if i > 0 && !keystrokes[i-1].IsWait() && delay > 0 {
+			time.Sleep(delay)
+		}
 
-				time.Sleep(100)
+		resp, sendErr := session.Put(url, nil, nil, &reqbodies[i])
+		if sendErr != nil {
+			// the response has the reason the key was rejected
+			if resp != nil {
+				if body, readErr := ResponseJSON(resp); readErr == nil && body["errors"] != nil {
+					sendErr = fmt.Errorf("%v %v", sendErr, body["errors"])
+				}
 			}
+			return &KeystrokeError{Index: i, Keystroke: keystroke, Err: sendErr}
 		}
+		resp.Body.Close()
 	}
 
 	return
@@ -690,51 +828,3 @@ func (vm *Vm) RemoveSshForwardUsernet() (err error) {
 
 	return
 }
-
-type AgentNetworkInterface struct {
-	MACAddress  string
-	IPAddresses []net.IP
-	Name        string
-	Statistics  map[string]int64
-}
-
-func (a *AgentNetworkInterface) UnmarshalJSON(b []byte) (err error) {
-	var intermediate struct {
-		HardwareAddress string `json:"hardware-address"`
-		IPAddresses     []struct {
-			IPAddress     string `json:"ip-address"`
-			IPAddressType string `json:"ip-address-type"`
-			Prefix        int    `json:"prefix"`
-		} `json:"ip-addresses"`
-		Name       string           `json:"name"`
-		Statistics map[string]int64 `json:"statistics"`
-	}
-
-	if err = json.Unmarshal(b, &intermediate); err == nil {
-		a.IPAddresses = make([]net.IP, len(intermediate.IPAddresses))
-		for idx, ip := range intermediate.IPAddresses {
-			a.IPAddresses[idx] = net.ParseIP(ip.IPAddress)
-			if a.IPAddresses[idx] == nil {
-				return fmt.Errorf("Could not parse %s as IP", ip.IPAddress)
-			}
-		}
-		a.MACAddress = intermediate.HardwareAddress
-		a.Name = intermediate.Name
-		a.Statistics = intermediate.Statistics
-	}
-
-	return
-}
-
-func (vm *Vm) GetAgentNetworkInterfaces() (ifs []AgentNetworkInterface, err error) {
-	if err = vm.Check(); err != nil {
-		return
-	}
-
-	url := fmt.Sprintf("/nodes/%s/%s/%d/agent/%s", vm.node.name, vm.vmtype, vm.id, "network-get-interfaces")
-	if resp, err := GetClient().session.Get(url, nil, nil); err == nil {
-		err = TypedResponse(resp, &ifs)
-	}
-
-	return
-}
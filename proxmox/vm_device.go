@@ -1,6 +1,7 @@
 package proxmox
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -23,6 +24,50 @@ func inArray(arr []string, str string) bool {
 	return false
 }
 
+// PVEBool - a bool that also decodes the 0/1 numbers and strings PVE uses
+// for flags in its responses
+type PVEBool bool
+
+func (b *PVEBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "1", "true":
+		*b = true
+	case "0", "false", "", "null":
+		*b = false
+	default:
+		return fmt.Errorf("Could not parse %s as bool", data)
+	}
+	return nil
+}
+
+// PVEList - a string list that PVE returns either as a JSON array or as a
+// comma separated string, depending on the endpoint
+type PVEList []string
+
+func (l *PVEList) UnmarshalJSON(data []byte) (err error) {
+	var list []string
+	if err = json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return
+	}
+
+	var str string
+	if err = json.Unmarshal(data, &str); err == nil {
+		*l = PVEList{}
+		for _, elem := range strings.Split(str, ",") {
+			if elem = strings.TrimSpace(elem); elem != "" {
+				*l = append(*l, elem)
+			}
+		}
+	}
+
+	return
+}
+
+func (l PVEList) String() string {
+	return strings.Join(l, ",")
+}
+
 type (
 	VmDevices     map[int]map[string]interface{}
 	VmDevice      map[string]interface{}
@@ -0,0 +1,32 @@
+package test
+
+import (
+	"github.com/3coma3/proxmox-api-go/keyboard"
+)
+
+func init() {
+	// arguments are the text and optionally the layout name
+	testActions["keyboard_parse"] = func(options *TOptions) (response interface{}, err error) {
+		layout := keyboard.Layout(keyboard.US)
+		if len(options.Args) > 2 {
+			if layout, err = keyboard.GetLayout(options.Args[2]); err != nil {
+				return
+			}
+		}
+
+		var keystrokes []keyboard.Keystroke
+		if keystrokes, err = keyboard.Parse(options.Args[1], layout); err == nil {
+			sequence := []string{}
+			for _, keystroke := range keystrokes {
+				sequence = append(sequence, keystroke.String())
+			}
+			response = sequence
+		}
+
+		return
+	}
+
+	testActions["keyboard_layoutnames"] = func(options *TOptions) (response interface{}, err error) {
+		return keyboard.LayoutNames(), nil
+	}
+}
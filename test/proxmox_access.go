@@ -0,0 +1,105 @@
+package test
+
+import (
+	"github.com/3coma3/proxmox-api-go/proxmox"
+	"encoding/json"
+	"os"
+)
+
+func init() {
+	testActions["access_getuserlist"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetUserList()
+	}
+
+	testActions["access_getuser"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetUser(options.Args[1])
+	}
+
+	// the user is read from stdin as JSON, the password is the second argument
+	testActions["access_createuser"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		user := proxmox.User{}
+		if err = json.NewDecoder(os.Stdin).Decode(&user); err != nil {
+			return
+		}
+
+		password := ""
+		if len(options.Args) > 1 {
+			password = options.Args[1]
+		}
+
+		return nil, proxmox.CreateUser(user, password)
+	}
+
+	testActions["access_updateuser"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		user := proxmox.User{}
+		if err = json.NewDecoder(os.Stdin).Decode(&user); err != nil {
+			return
+		}
+
+		return nil, proxmox.UpdateUser(user)
+	}
+
+	testActions["access_deleteuser"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return nil, proxmox.DeleteUser(options.Args[1])
+	}
+
+	testActions["access_getapitokenlist"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetApiTokenList(options.Args[1])
+	}
+
+	testActions["access_createapitoken"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		privsep := true
+		return proxmox.CreateApiToken(options.Args[1], proxmox.ApiToken{TokenID: options.Args[2], Privsep: &privsep})
+	}
+
+	testActions["access_deleteapitoken"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return nil, proxmox.DeleteApiToken(options.Args[1], options.Args[2])
+	}
+
+	testActions["access_getgrouplist"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetGroupList()
+	}
+
+	testActions["access_getrolelist"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetRoleList()
+	}
+
+	testActions["access_getrole"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetRole(options.Args[1])
+	}
+
+	testActions["access_getrealmlist"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetRealmList()
+	}
+
+	testActions["access_getacl"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetACL()
+	}
+
+	// the entry is read from stdin as JSON
+	testActions["access_addacl"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		acl := proxmox.ACLEntry{}
+		if err = json.NewDecoder(os.Stdin).Decode(&acl); err != nil {
+			return
+		}
+
+		return nil, proxmox.AddACL(acl)
+	}
+}
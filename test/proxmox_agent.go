@@ -0,0 +1,118 @@
+package test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func init() {
+	testActions["vm_agentping"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return nil, vm.AgentPing()
+	}
+
+	testActions["vm_getagentosinfo"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetAgentOSInfo()
+	}
+
+	testActions["vm_getagenthostname"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetAgentHostName()
+	}
+
+	testActions["vm_getagentusers"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetAgentUsers()
+	}
+
+	testActions["vm_getagenttime"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetAgentTime()
+	}
+
+	testActions["vm_getagentfsinfo"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetAgentFsInfo()
+	}
+
+	testActions["vm_getagentvcpus"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetAgentVCPUs()
+	}
+
+	testActions["vm_getagentmemoryblocks"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetAgentMemoryBlocks()
+	}
+
+	testActions["vm_agentfsfreeze"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.AgentFsFreeze()
+	}
+
+	testActions["vm_agentfsthaw"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.AgentFsThaw()
+	}
+
+	testActions["vm_agentfsfreezestatus"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.AgentFsFreezeStatus()
+	}
+
+	testActions["vm_agentfstrim"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.AgentFstrim()
+	}
+
+	testActions["vm_agentshutdown"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return nil, vm.AgentShutdown()
+	}
+
+	testActions["vm_agentsuspenddisk"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return nil, vm.AgentSuspendDisk()
+	}
+
+	testActions["vm_agentsuspendram"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return nil, vm.AgentSuspendRam()
+	}
+
+	// arguments are the user and the password
+	testActions["vm_agentsetuserpassword"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return nil, vm.AgentSetUserPassword(options.Args[1], options.Args[2], false)
+	}
+
+	testActions["vm_agentfileread"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.AgentFileRead(options.Args[1])
+	}
+
+	// the file contents are read from stdin
+	testActions["vm_agentfilewrite"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		var content []byte
+		if content, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return
+		}
+
+		return nil, vm.AgentFileWrite(options.Args[1], content)
+	}
+
+	// the command and its arguments follow the vmid, it gets a minute to exit
+	testActions["vm_agentexec"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		return vm.AgentExec(ctx, options.Args[1:], "", nil)
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"github.com/3coma3/proxmox-api-go/proxmox"
+	"encoding/json"
+	"os"
+	"strconv"
+)
+
+func init() {
+	testActions["backup_getbackupjoblist"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetBackupJobList()
+	}
+
+	testActions["backup_getbackupjob"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.GetBackupJob(options.Args[1])
+	}
+
+	// the job is read from stdin as JSON
+	testActions["backup_createbackupjob"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		job := proxmox.BackupJob{}
+		if err = json.NewDecoder(os.Stdin).Decode(&job); err != nil {
+			return
+		}
+
+		return nil, proxmox.CreateBackupJob(job)
+	}
+
+	testActions["backup_deletebackupjob"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return nil, proxmox.DeleteBackupJob(options.Args[1])
+	}
+
+	// arguments are the node and the backup volume id
+	testActions["backup_deletebackup"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.NewNode(options.Args[1]).DeleteBackup(options.Args[2])
+	}
+
+	// arguments are the node, the backup volume id and true/false
+	testActions["backup_setbackupprotected"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		var protected bool
+		if protected, err = strconv.ParseBool(options.Args[3]); err != nil {
+			return
+		}
+
+		return nil, proxmox.NewNode(options.Args[1]).SetBackupProtected(options.Args[2], protected)
+	}
+
+	// arguments are the node, the backup volume id and the target storage
+	testActions["restore_restorenew"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		vm, exitStatus, err := proxmox.RestoreNew(proxmox.NewNode(options.Args[1]), options.VMid, options.Args[2], options.Args[3])
+		if err == nil {
+			DebugMsg("restored as vmid " + strconv.Itoa(vm.Id()))
+		}
+
+		return exitStatus, err
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"github.com/3coma3/proxmox-api-go/proxmox"
+	"encoding/json"
+	"os"
+)
+
+func init() {
+	// the filter is read from stdin as JSON
+	testActions["bulk_findvms"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		filter := proxmox.BulkFilter{}
+		if err = json.NewDecoder(os.Stdin).Decode(&filter); err != nil {
+			return
+		}
+
+		var vms []*proxmox.Vm
+		if vms, err = proxmox.FindVms(filter); err == nil {
+			vmids := []int{}
+			for _, vm := range vms {
+				vmids = append(vmids, vm.Id())
+			}
+			response = vmids
+		}
+
+		return
+	}
+
+	// stdin has the guests filter, the operation and the run options:
+	// {"filter": {...}, "operation": {"action": "start"}, "options": {"workers": 4}}
+	testActions["bulk_runbulk"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		input := struct {
+			Filter    proxmox.BulkFilter    `json:"filter"`
+			Operation proxmox.BulkOperation `json:"operation"`
+			Options   proxmox.BulkOptions   `json:"options"`
+		}{}
+		if err = json.NewDecoder(os.Stdin).Decode(&input); err != nil {
+			return
+		}
+
+		var vms []*proxmox.Vm
+		if vms, err = proxmox.FindVms(input.Filter); err != nil {
+			return
+		}
+
+		return proxmox.RunBulk(vms, input.Operation, input.Options)
+	}
+}
@@ -15,4 +15,15 @@ func init() {
 		client, _ := newClientAndVmr(options)
 		return client.GetTaskExitstatus(options.Args[1])
 	}
+
+	testActions["client_permissions"] = func(options *TOptions) (response interface{}, err error) {
+		client, _ := newClientAndVmr(options)
+		return client.Permissions(options.Args[1])
+	}
+
+	// arguments are the path followed by the privileges to check
+	testActions["client_cando"] = func(options *TOptions) (response interface{}, err error) {
+		client, _ := newClientAndVmr(options)
+		return nil, client.CanDo(options.Args[1], options.Args[2:]...)
+	}
 }
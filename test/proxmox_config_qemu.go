@@ -22,6 +22,45 @@ func init() {
 	// simple method
 	testActions["configqemu_hascloudinit"] = errNotImplemented
 
+	testActions["configqemu_parseipconfig"] = func(options *TOptions) (response interface{}, err error) {
+		var ipconfig proxmox.IPConfig
+		if ipconfig, err = proxmox.ParseIPConfig(options.Args[1]); err == nil {
+			response, err = ipconfig, ipconfig.Validate()
+		}
+		return
+	}
+
+	// the user-data JSON is read from stdin, the rendered document is printed
+	testActions["configqemu_renderuserdata"] = func(options *TOptions) (response interface{}, err error) {
+		var userData proxmox.CloudInitUserData
+		if err = json.NewDecoder(os.Stdin).Decode(&userData); err != nil {
+			return
+		}
+
+		var content []byte
+		if content, err = proxmox.RenderUserData(userData); err == nil {
+			response = string(content)
+		}
+
+		return
+	}
+
+	// the network-config JSON is read from stdin, the rendered document is
+	// printed
+	testActions["configqemu_rendernetworkconfig"] = func(options *TOptions) (response interface{}, err error) {
+		var networkConfig proxmox.CloudInitNetworkConfig
+		if err = json.NewDecoder(os.Stdin).Decode(&networkConfig); err != nil {
+			return
+		}
+
+		var content []byte
+		if content, err = proxmox.RenderNetworkConfig(networkConfig); err == nil {
+			response = string(content)
+		}
+
+		return
+	}
+
 	testActions["configqemu_updateconfig"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"github.com/3coma3/proxmox-api-go/proxmox"
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// the firewall scope is selected by the first action argument, one of
+// "cluster", "node:<nodename>" or "vm" (for the guest in -vmid)
+func newFirewall(options *TOptions) (fw *proxmox.Firewall, err error) {
+	_, vm := newClientAndVmr(options)
+
+	switch scope := options.Args[1]; {
+	case scope == "cluster":
+		fw = proxmox.NewClusterFirewall()
+	case strings.HasPrefix(scope, "node:"):
+		fw = proxmox.NewNode(strings.TrimPrefix(scope, "node:")).Firewall()
+	default:
+		fw, err = vm.Firewall()
+	}
+
+	return
+}
+
+func init() {
+	// factories
+	testActions["firewall_newclusterfirewall"] = errNotImplemented
+	testActions["firewall_nodefirewall"] = errNotImplemented
+	testActions["firewall_vmfirewall"] = errNotImplemented
+
+	testActions["firewall_getrules"] = func(options *TOptions) (response interface{}, err error) {
+		var fw *proxmox.Firewall
+		if fw, err = newFirewall(options); err != nil {
+			return
+		}
+
+		return fw.Rules().GetList()
+	}
+
+	// the desired rule list is read from stdin as a JSON array
+	testActions["firewall_syncrules"] = func(options *TOptions) (response interface{}, err error) {
+		var fw *proxmox.Firewall
+		if fw, err = newFirewall(options); err != nil {
+			return
+		}
+
+		desired := []proxmox.FirewallRule{}
+		if err = json.NewDecoder(os.Stdin).Decode(&desired); err != nil {
+			return
+		}
+
+		return fw.Rules().Sync(desired)
+	}
+
+	testActions["firewall_getsecuritygrouplist"] = func(options *TOptions) (response interface{}, err error) {
+		var fw *proxmox.Firewall
+		if fw, err = newFirewall(options); err != nil {
+			return
+		}
+
+		return fw.GetSecurityGroupList()
+	}
+
+	testActions["firewall_getipsetlist"] = func(options *TOptions) (response interface{}, err error) {
+		var fw *proxmox.Firewall
+		if fw, err = newFirewall(options); err != nil {
+			return
+		}
+
+		return fw.GetIPSetList()
+	}
+
+	testActions["firewall_getaliaslist"] = func(options *TOptions) (response interface{}, err error) {
+		var fw *proxmox.Firewall
+		if fw, err = newFirewall(options); err != nil {
+			return
+		}
+
+		return fw.GetAliasList()
+	}
+
+	testActions["firewall_getoptions"] = func(options *TOptions) (response interface{}, err error) {
+		var fw *proxmox.Firewall
+		if fw, err = newFirewall(options); err != nil {
+			return
+		}
+
+		return fw.GetOptions()
+	}
+}
@@ -116,4 +116,28 @@ func init() {
 
 		return
 	}
+
+	// with a second "onboot" argument only guests set to start on boot are started
+	testActions["node_startall"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		onbootOnly := len(options.Args) > 2 && options.Args[2] == "onboot"
+		return proxmox.NewNode(options.Args[1]).StartAll(onbootOnly)
+	}
+
+	testActions["node_stopall"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.NewNode(options.Args[1]).StopAll()
+	}
+
+	// with a second "all" argument the classes PVE filters are listed too
+	testActions["node_listpcidevices"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		all := len(options.Args) > 2 && options.Args[2] == "all"
+		return proxmox.NewNode(options.Args[1]).ListPCIDevices(all)
+	}
+
+	testActions["node_listusbdevices"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.NewNode(options.Args[1]).ListUSBDevices()
+	}
 }
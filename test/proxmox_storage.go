@@ -2,6 +2,7 @@ package test
 
 import (
 	"github.com/3coma3/proxmox-api-go/proxmox"
+	"os"
 )
 
 func init() {
@@ -28,4 +29,48 @@ func init() {
 		return proxmox.NewStorage(options.Args[1]).GetInfo()
 	}
 
+	// arguments are the storage, the node and optionally the content type
+	testActions["storage_getcontent"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		content := ""
+		if len(options.Args) > 3 {
+			content = options.Args[3]
+		}
+
+		return proxmox.NewStorage(options.Args[1]).GetContent(proxmox.NewNode(options.Args[2]), content, 0)
+	}
+
+	// arguments are the PBS storage and the node
+	testActions["storage_getpbssnapshotlist"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+		return proxmox.NewStorage(options.Args[1]).GetPBSSnapshotList(proxmox.NewNode(options.Args[2]), 0)
+	}
+
+	// arguments are the PBS storage, the node, the snapshot volume id and
+	// optionally the base64 path to list
+	testActions["storage_listsnapshotfiles"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		filepath := ""
+		if len(options.Args) > 4 {
+			filepath = options.Args[4]
+		}
+
+		return proxmox.NewStorage(options.Args[1]).ListSnapshotFiles(proxmox.NewNode(options.Args[2]), options.Args[3], filepath)
+	}
+
+	// arguments are the PBS storage, the node, the snapshot volume id, the
+	// base64 path to download and the local file to save it to
+	testActions["storage_downloadsnapshotfile"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		var file *os.File
+		if file, err = os.Create(options.Args[5]); err != nil {
+			return
+		}
+		defer file.Close()
+
+		return proxmox.NewStorage(options.Args[1]).DownloadSnapshotFile(proxmox.NewNode(options.Args[2]), options.Args[3], options.Args[4], file)
+	}
 }
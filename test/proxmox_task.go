@@ -0,0 +1,40 @@
+package test
+
+import (
+	"github.com/3coma3/proxmox-api-go/proxmox"
+)
+
+func init() {
+	testActions["task_getstatus"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		var task *proxmox.Task
+		if task, err = proxmox.NewTask(options.Args[1]); err == nil {
+			response, err = task.GetStatus()
+		}
+
+		return
+	}
+
+	testActions["task_wait"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		var task *proxmox.Task
+		if task, err = proxmox.NewTask(options.Args[1]); err == nil {
+			response, err = task.Wait()
+		}
+
+		return
+	}
+
+	testActions["task_stop"] = func(options *TOptions) (response interface{}, err error) {
+		_, _ = newClientAndVmr(options)
+
+		var task *proxmox.Task
+		if task, err = proxmox.NewTask(options.Args[1]); err == nil {
+			err = task.Stop()
+		}
+
+		return
+	}
+}
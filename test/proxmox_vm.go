@@ -1,10 +1,14 @@
 package test
 
 import (
+	"github.com/3coma3/proxmox-api-go/keyboard"
 	"github.com/3coma3/proxmox-api-go/proxmox"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -56,17 +60,81 @@ func init() {
 	testActions["vm_clone"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 
-		cloneParams := map[string]interface{}{}
-		if err = json.NewDecoder(os.Stdin).Decode(&cloneParams); err == nil {
+		opts := proxmox.CloneOptions{}
+		if err = json.NewDecoder(os.Stdin).Decode(&opts); err == nil {
 			DebugMsg("Looking for template: " + options.VMname)
 			if sourceVm, err := proxmox.FindVm(options.VMname); err == nil && sourceVm != nil {
-				return sourceVm.Clone(vm.Id(), cloneParams)
+				return sourceVm.Clone(vm.Id(), opts)
 			}
 		}
 
 		return
 	}
 
+	// stdin has the clone options and the config to apply after cloning:
+	// {"clone": {...}, "config": {...}}, the config type follows the template
+	testActions["vm_cloneandconfigure"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		input := struct {
+			Clone  proxmox.CloneOptions `json:"clone"`
+			Config json.RawMessage      `json:"config"`
+		}{}
+		if err = json.NewDecoder(os.Stdin).Decode(&input); err != nil {
+			return
+		}
+
+		var sourceVm *proxmox.Vm
+		if sourceVm, err = proxmox.FindVm(options.VMname); err != nil {
+			return
+		}
+
+		var config proxmox.VmConfigUpdater
+		if sourceVm.Type() == "qemu" {
+			config, err = proxmox.NewConfigQemuFromJson(bytes.NewReader(input.Config))
+		} else {
+			config, err = proxmox.NewConfigLxcFromJson(bytes.NewReader(input.Config), true)
+		}
+		if err != nil {
+			return
+		}
+
+		_, response, err = sourceVm.CloneAndConfigure(vm.Id(), input.Clone, config)
+
+		return
+	}
+
+	testActions["vm_getstartuporder"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetStartupOrder()
+	}
+
+	// parse each startup option given and parse its string form back, which
+	// has to give the same order. Without arguments some samples are used
+	testActions["vm_parsestartuporder"] = func(options *TOptions) (response interface{}, err error) {
+		samples := options.Args[1:]
+		if len(samples) == 0 {
+			samples = []string{"3", "order=0", "3,up=10,down=5"}
+		}
+
+		roundTrips := map[string]string{}
+		for _, startup := range samples {
+			var so, reparsed proxmox.StartupOrder
+			if so, err = proxmox.ParseStartupOrder(startup); err != nil {
+				return
+			}
+			if reparsed, err = proxmox.ParseStartupOrder(so.String()); err != nil {
+				return
+			}
+			if reparsed.String() != so.String() {
+				return nil, fmt.Errorf("Startup '%s' parsed as '%s' doesn't round-trip", startup, so.String())
+			}
+			roundTrips[startup] = so.String()
+		}
+
+		return roundTrips, nil
+	}
+
 	testActions["vm_delete"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 		return vm.Delete()
@@ -134,13 +202,24 @@ func init() {
 	testActions["vm_migrate"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 
-		migrateParams := map[string]interface{}{}
-		if err = json.NewDecoder(os.Stdin).Decode(&migrateParams); err != nil {
+		opts := proxmox.MigrateOptions{}
+		if err = json.NewDecoder(os.Stdin).Decode(&opts); err != nil {
 			return
 		}
 
-		migrateParams["target"] = options.Args[1]
-		return vm.Migrate(migrateParams)
+		opts.Target = options.Args[1]
+		return vm.Migrate(opts)
+	}
+
+	testActions["vm_getmigrateprecondition"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		target := ""
+		if len(options.Args) > 1 {
+			target = options.Args[1]
+		}
+
+		return vm.GetMigratePrecondition(target)
 	}
 
 	testActions["vm_getsnapshotlist"] = func(options *TOptions) (response interface{}, err error) {
@@ -151,13 +230,54 @@ func init() {
 	testActions["vm_createsnapshot"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 
-		snapParams := map[string]interface{}{}
-		if err = json.NewDecoder(os.Stdin).Decode(&snapParams); err != nil {
+		opts := proxmox.SnapshotOptions{}
+		if err = json.NewDecoder(os.Stdin).Decode(&opts); err != nil {
+			return
+		}
+
+		opts.Name = options.Args[1]
+		return vm.CreateSnapshot(opts)
+	}
+
+	testActions["vm_getsnapshots"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetSnapshots()
+	}
+
+	testActions["vm_getsnapshottree"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		var snaps []*proxmox.Snapshot
+		if snaps, err = vm.GetSnapshots(); err == nil {
+			response = proxmox.NewSnapshotTree(snaps).String()
+		}
+
+		return
+	}
+
+	// the policy is read from stdin as JSON, pass "dryrun" as argument to only
+	// list the snapshots that would be deleted
+	testActions["vm_applysnapshotretention"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		policy := proxmox.SnapshotRetentionPolicy{}
+		if err = json.NewDecoder(os.Stdin).Decode(&policy); err != nil {
 			return
 		}
 
-		snapParams["snapname"] = options.Args[1]
-		return vm.CreateSnapshot(snapParams)
+		dryRun := len(options.Args) > 1 && options.Args[1] == "dryrun"
+		return vm.ApplySnapshotRetention(policy, dryRun)
+	}
+
+	testActions["vm_updatesnapshot"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		var snap *proxmox.Snapshot
+		if snap, err = vm.GetSnapshot(options.Args[1]); err == nil {
+			err = snap.Update(strings.Join(options.Args[2:], " "))
+		}
+
+		return
 	}
 
 	testActions["vm_deletesnapshot"] = func(options *TOptions) (response interface{}, err error) {
@@ -165,6 +285,18 @@ func init() {
 		return vm.DeleteSnapshot(options.Args[1])
 	}
 
+	// returns the task UPID right away, see the task_* actions
+	testActions["vm_deletesnapshotasync"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		var task *proxmox.Task
+		if task, err = vm.DeleteSnapshotAsync(options.Args[1]); err == nil && task != nil {
+			response = task.Upid()
+		}
+
+		return
+	}
+
 	testActions["vm_rollback"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 		return vm.Rollback(options.Args[1])
@@ -173,12 +305,30 @@ func init() {
 	testActions["vm_createbackup"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 
-		bkpParams := map[string]interface{}{}
-		if err = json.NewDecoder(os.Stdin).Decode(&bkpParams); err != nil {
+		opts := proxmox.VzdumpOptions{}
+		if err = json.NewDecoder(os.Stdin).Decode(&opts); err != nil {
 			return
 		}
 
-		return vm.CreateBackup(bkpParams)
+		return vm.CreateBackup(opts)
+	}
+
+	testActions["vm_getbackuplist"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+		return vm.GetBackupList()
+	}
+
+	// the backup volume id is the first argument, the restore options are
+	// read from stdin as JSON
+	testActions["vm_restorefrom"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		opts := proxmox.RestoreOptions{}
+		if err = json.NewDecoder(os.Stdin).Decode(&opts); err != nil {
+			return
+		}
+
+		return vm.RestoreFrom(options.Args[1], opts)
 	}
 
 	testActions["vm_movedisk"] = func(options *TOptions) (response interface{}, err error) {
@@ -197,6 +347,36 @@ func init() {
 		return vm.ResizeDisk(options.Args[1], options.Args[2])
 	}
 
+	// arguments are the image, the storage and optionally the disk format
+	testActions["vm_importdisk"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		format := ""
+		if len(options.Args) > 3 {
+			format = options.Args[3]
+		}
+
+		var unused, volid string
+		if unused, volid, err = vm.ImportDisk(options.Args[1], options.Args[2], format); err == nil {
+			response = map[string]string{"unused": unused, "volid": volid}
+		}
+
+		return
+	}
+
+	// arguments are the unusedN key or volume, the slot and optionally the
+	// disk options
+	testActions["vm_attachdisk"] = func(options *TOptions) (response interface{}, err error) {
+		_, vm := newClientAndVmr(options)
+
+		diskOptions := ""
+		if len(options.Args) > 3 {
+			diskOptions = options.Args[3]
+		}
+
+		return vm.AttachDisk(options.Args[1], options.Args[2], diskOptions)
+	}
+
 	testActions["vm_getspiceproxy"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
 		return vm.GetSpiceProxy()
@@ -207,9 +387,19 @@ func init() {
 		return vm.MonitorCmd(strings.Join(append(options.Args[:0], options.Args[+1:]...), " "))
 	}
 
+	// the text is the first argument, optionally followed by the delay
+	// between keystrokes as a Go duration like 200ms
 	testActions["vm_sendkeysstring"] = func(options *TOptions) (response interface{}, err error) {
 		_, vm := newClientAndVmr(options)
-		return nil, vm.SendKeysString(options.Args[1])
+
+		delay := keyboard.DefaultDelay
+		if len(options.Args) > 2 {
+			if delay, err = time.ParseDuration(options.Args[2]); err != nil {
+				return
+			}
+		}
+
+		return nil, vm.SendKeysStringWithLayout(options.Args[1], keyboard.US, delay)
 	}
 
 	testActions["vm_sshforwardusernet"] = func(options *TOptions) (response interface{}, err error) {